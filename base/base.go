@@ -21,10 +21,18 @@ type Numeric interface {
 	Integer | ~float32 | ~float64 | ~complex64 | ~complex128
 }
 
+type Ordered interface {
+	Real | ~string
+}
+
+type Real interface {
+	Integer | ~float32 | ~float64
+}
+
 type Receiver[T any] interface {
-	~<-chan T
+	~chan T | ~<-chan T
 }
 
 type Sender[T any] interface {
-	~chan<- T
+	~chan T | ~chan<- T
 }
@@ -14,27 +14,58 @@
 package compile
 
 import (
+	"errors"
+
 	"github.com/dave/jennifer/jen"
 	"github.com/dvaumoron/foresee/builtins/names"
+	"github.com/dvaumoron/foresee/diagnostic"
 	"github.com/dvaumoron/foresee/types"
 )
 
 const (
 	// user can not directly use this kind of id (# start a comment)
-	hiddenImportsName = "#imports"
-	hiddenPackageName = "#package"
+	hiddenImportsName  = "#imports"
+	hiddenLineFileName = "#lineFile"
+	hiddenPackageName  = "#package"
+	hiddenResultsName  = "#results"
 
 	mainId types.Identifier = "main"
+
+	// first line of every generated file (following the Go convention)
+	GeneratedHeader = "// Code generated by foresee. DO NOT EDIT."
 )
 
 var (
+	errAssignableType = errors.New("wait assignable target")
+	errBlockType      = errors.New("wait instruction list")
+	errCondition      = errors.New("wait condition")
+	errDefinition     = errors.New("wait name with a value or name:type with an optional value")
+	errFuncName       = errors.New("wait function name or receiver")
+	errIdentifierType = errors.New("wait identifier type")
+	errLoopHeader     = errors.New("unhandled loop header")
+	errPairSize       = errors.New("wait at least 2 elements")
+	errParameterList  = errors.New("wait parameter list in name:type format")
+	errStructTag      = errors.New("wait struct tag in key:\"value\" format")
+	errTripleSize     = errors.New("wait at least 3 elements")
+	errTryResults     = errors.New("wait enclosing function with error as last result")
+	errTypeDef        = errors.New("unhandled type definition")
+	errTypeExpected   = errors.New("wait type")
+	errUnarySize      = errors.New("wait 1 argument")
+
+	// placeholder for failing form (the diagnostics forbid to use the generated code)
 	wrappedErrorComment = wrapper{Renderer: jen.Comment("/* encounter errors, can't generate correct go code */")}
 
 	Builtins = initBuitins()
 )
 
-func Compile(l *types.List) types.Object {
-	return l.Eval(compileEnvironment{Environment: types.MakeLocalEnvironment(Builtins)})
+// The returned object must not be rendered when diagnostics contains errors,
+// lineFile is the source name used in the line directives (none when empty).
+func Compile(l *types.List, lineFile string) (types.Object, diagnostic.List) {
+	var collector diagnostic.Collector
+	local := types.MakeLocalEnvironment(Builtins)
+	local.StoreStr(hiddenLineFileName, types.String(lineFile))
+	env := makeCompileEnvironment(local, &collector, l.Span())
+	return l.Eval(env), collector.Diagnostics()
 }
 
 func initBuitins() types.BaseEnvironment {
@@ -75,6 +106,8 @@ func initBuitins() types.BaseEnvironment {
 	base.StoreStr(names.If, types.MakeNativeAppliable(ifForm))
 	base.StoreStr(names.Import, types.MakeNativeAppliable(importForm))
 	base.StoreStr(names.Increment, types.MakeNativeAppliable(incrementForm))
+	base.StoreStr(string(names.InstId), types.MakeNativeAppliable(instantiateForm))
+	base.StoreStr(string(names.InterfaceId), types.MakeNativeAppliable(interfaceTypeForm))
 	base.StoreStr(names.Label, types.MakeNativeAppliable(labelForm))
 	base.StoreStr(names.Lambda, types.MakeNativeAppliable(lambdaForm))
 	base.StoreStr(names.Lesser, types.MakeNativeAppliable(lesserForm))
@@ -104,8 +137,10 @@ func initBuitins() types.BaseEnvironment {
 	base.StoreStr(string(names.SliceId), types.MakeNativeAppliable(sliceOrArrayTypeForm))
 	base.StoreStr(string(names.StarId), types.MakeNativeAppliable(dereferenceOrMultiplyForm))
 	base.StoreStr(string(names.StoreId), types.MakeNativeAppliable(storeForm))
+	base.StoreStr(string(names.StructId), types.MakeNativeAppliable(structTypeForm))
 	base.StoreStr(names.SubAssign, types.MakeNativeAppliable(substractAssignForm))
 	base.StoreStr(names.Switch, types.MakeNativeAppliable(switchForm))
+	base.StoreStr(names.Try, types.MakeNativeAppliable(tryForm))
 	base.StoreStr(names.Type, types.MakeNativeAppliable(typeForm))
 	base.StoreStr(names.Var, types.MakeNativeAppliable(varForm))
 	base.StoreStr(names.XorAssign, types.MakeNativeAppliable(bitwiseXOrAssignForm))
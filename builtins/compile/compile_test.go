@@ -0,0 +1,77 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package compile_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dvaumoron/foresee/builtins/compile"
+	"github.com/dvaumoron/foresee/parser"
+	"github.com/dvaumoron/foresee/types"
+)
+
+func compileSource(t *testing.T, source string) string {
+	t.Helper()
+
+	l, err := parser.New().Parse("test.fc", strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compiled, diagnostics := compile.Compile(l, "test.fc")
+	if len(diagnostics) != 0 {
+		t.Fatal(diagnostics)
+	}
+
+	var buffer strings.Builder
+	if err = compiled.(types.Renderer).Render(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.String()
+}
+
+// a line directive alone would apply to the following line
+func TestNoDirectiveBeforeEmpty(t *testing.T) {
+	res := compileSource(t, `package p
+
+func F() int
+    := x 1
+    1
+    return x
+`)
+	if !strings.Contains(res, "/*line test.fc:6:5*/ return x") {
+		t.Errorf("wait the return at line 6, got :\n%s", res)
+	}
+	if strings.Contains(res, "test.fc:5:") {
+		t.Errorf("wait no directive for the empty instruction at line 5, got :\n%s", res)
+	}
+}
+
+func TestNamedResults(t *testing.T) {
+	res := compileSource(t, `package p
+
+func Div(a:int b:int) (list q:int err:error)
+    if (== b 0)
+        return
+    = q (/ a b)
+    return
+`)
+	if !strings.Contains(res, "func Div(a int, b int) (q int, err error) {") {
+		t.Errorf("wait named results, got :\n%s", res)
+	}
+	if strings.Count(res, "return\n") != 2 {
+		t.Errorf("wait two naked returns, got :\n%s", res)
+	}
+}
@@ -14,8 +14,11 @@
 package compile
 
 import (
+	"fmt"
 	"iter"
 	"math"
+	"strconv"
+	"strings"
 
 	"github.com/dave/jennifer/jen"
 	"github.com/dvaumoron/foresee/builtins/names"
@@ -30,7 +33,73 @@ func compileToCodeSlice(env types.Environment, it iter.Seq[types.Object]) []jen.
 	return codes
 }
 
+// same as compileToCodeSlice for the declarations of a file, each one is preceded by a line directive
+// (when Compile received a source name)
+func compileToDeclarations(env types.Environment, it iter.Seq[types.Object]) []jen.Code {
+	var codes []jen.Code
+	for elem := range it {
+		code := compileToCode(env, elem)
+		if directive := lineDirective(env, elem, "//line %s:%d:%d"); directive != nil {
+			// the line form must start the line, even after a declaration without block
+			code = directive.Line().Add(code).Line()
+		}
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+// same as compileToCodeSlice for instructions (see compileToInstruction)
+func compileToInstructions(env types.Environment, it iter.Seq[types.Object]) []jen.Code {
+	var codes []jen.Code
+	for elem := range it {
+		codes = append(codes, compileToInstruction(env, elem))
+	}
+	return codes
+}
+
+// the code is preceded by a line directive when Compile received a source name
+// (block form, the line form must start the line and the code is indented)
+func compileToInstruction(env types.Environment, object types.Object) jen.Code {
+	code := compileToCode(env, object)
+	if isEmptyCode(code) {
+		return jen.Null() // a directive alone would apply to the next line
+	}
+	if directive := lineDirective(env, object, "/*line %s:%d:%d*/"); directive != nil {
+		return directive.Add(code)
+	}
+	return code
+}
+
+func isEmptyCode(code Renderer) bool {
+	statement, ok := code.(*jen.Statement)
+	return ok && len(*statement) <= 1 && statement.GoString() == ""
+}
+
+func lineDirective(env types.Environment, object types.Object, format string) *jen.Statement {
+	lineFile, _ := env.LoadStr(hiddenLineFileName)
+	castedFile, _ := lineFile.(types.String)
+	list, ok := object.(*types.List)
+	if castedFile == "" || !ok {
+		return nil
+	}
+
+	switch header, _ := list.LoadInt(0).(types.Identifier); header {
+	case names.Package, names.Import:
+		return nil // no code
+	}
+
+	span := list.Span()
+	if !span.IsValid() {
+		return nil
+	}
+	return jen.Comment(fmt.Sprintf(format, castedFile, span.Start.Line, span.Start.Col))
+}
+
 func compileToCode(env types.Environment, object types.Object) Renderer {
+	if located, ok := object.(types.Locatable); ok {
+		defer locate(env, located.Span())()
+	}
+
 	return handleBasicType(object, true, func(object types.Object) Renderer {
 		switch casted := object.Eval(env).(type) {
 		case callableWrapper:
@@ -87,6 +156,18 @@ func extractType(env types.Environment, object types.Object) *jen.Statement {
 }
 
 func extractTypeFromList(env types.Environment, casted *types.List) *jen.Statement {
+	switch header, _ := casted.LoadInt(0).(types.Identifier); header {
+	case names.StructId, names.InterfaceId:
+		next, stop := types.Pull(casted.Iter())
+		defer stop()
+		next() // skip header
+
+		if header == names.StructId {
+			return jen.Struct(extractFields(env, types.Push(next))...)
+		}
+		return jen.Interface(extractMethods(env, types.Push(next))...)
+	}
+
 	switch casted.Size() {
 	case 2:
 		switch op, _ := casted.LoadInt(0).(types.Identifier); op {
@@ -99,13 +180,8 @@ func extractTypeFromList(env types.Environment, casted *types.List) *jen.Stateme
 		case names.SliceId:
 			return buildParameterizedType(env, jen.Index(), casted)
 		case names.FuncId:
-			params, ok := casted.LoadInt(1).(*types.List)
-			if !ok {
-				return nil
-			}
-
-			if typeCodes, ok := extractTypes(env, params); ok {
-				return jen.Func().Params(typeCodes...)
+			if params, ok := casted.LoadInt(1).(*types.List); ok {
+				return extractSignature(env, jen.Func(), params, nil)
 			}
 		case names.EllipsisId, names.StarId, names.TildeId:
 			return buildParameterizedType(env, jen.Op(string(op)), casted)
@@ -115,30 +191,9 @@ func extractTypeFromList(env types.Environment, casted *types.List) *jen.Stateme
 		case names.FuncId:
 			params, ok := casted.LoadInt(1).(*types.List)
 			returns, ok2 := casted.LoadInt(2).(*types.List)
-			if !(ok && ok2) {
-				return nil
-			}
-
-			typeCodes, ok := extractTypes(env, params)
-			if !ok {
-				return nil
+			if ok && ok2 {
+				return extractSignature(env, jen.Func(), params, returns)
 			}
-
-			outputTypeIds, ok := extractTypes(env, returns)
-			if !ok {
-				return nil
-			}
-
-			funcCode := jen.Func().Params(typeCodes...)
-			switch len(outputTypeIds) {
-			case 0:
-				// no return
-				return funcCode
-			case 1:
-				// single return type
-				return funcCode.Add(outputTypeIds[0])
-			}
-			return funcCode.Parens(jen.List(outputTypeIds...))
 		case names.GenId:
 			return extractGenType(env, casted.LoadInt(1), casted.LoadInt(2))
 		case names.GetId:
@@ -153,6 +208,164 @@ func extractTypeFromList(env types.Environment, casted *types.List) *jen.Stateme
 	return nil
 }
 
+// add the params and the returns (could be nil) to base ("func" or a method name)
+func extractSignature(env types.Environment, base *jen.Statement, params *types.List, returns *types.List) *jen.Statement {
+	typeCodes, ok := extractTypes(env, params)
+	if !ok {
+		return nil
+	}
+
+	funcCode := base.Params(typeCodes...)
+	if returns == nil {
+		return funcCode
+	}
+
+	outputTypeIds, ok := extractResults(env, returns)
+	if !ok {
+		return nil
+	}
+
+	switch len(outputTypeIds) {
+	case 0:
+		// no return
+		return funcCode
+	case 1:
+		// single return type
+		if !isNamedResult(returns.LoadInt(1)) {
+			return funcCode.Add(outputTypeIds[0])
+		}
+	}
+	return funcCode.Parens(jen.List(outputTypeIds...))
+}
+
+// handle the elements of "struct[name:type other]", other is an embedded type
+func extractFields(env types.Environment, fields iter.Seq[types.Object]) []jen.Code {
+	var fieldCodes []jen.Code
+	for field := range fields {
+		if casted, ok := field.(*types.List); ok {
+			if header, _ := casted.LoadInt(0).(types.Identifier); header == names.ListId {
+				fieldId, _ := casted.LoadInt(1).(types.Identifier)
+				fieldCodes = append(fieldCodes, jen.Id(string(fieldId)).Add(extractType(env, casted.LoadInt(2))))
+				continue
+			}
+		}
+
+		if typeCode := extractType(env, field); typeCode != nil {
+			fieldCodes = append(fieldCodes, typeCode)
+		}
+	}
+	return fieldCodes
+}
+
+// handle "(name type tags...)", "((list name type) tags...)" and "(type tags...)" (embedded type),
+// tags are string literals ("key:\"value\"") or pairs (key:"value")
+func extractFieldDecl(env types.Environment, fieldDesc *types.List) jen.Code {
+	var fieldCode *jen.Statement
+	tagStart := 1
+	switch casted := fieldDesc.LoadInt(0).(type) {
+	case types.Identifier:
+		if fieldDesc.Size() == 1 || isTag(fieldDesc.LoadInt(1)) {
+			fieldCode = jen.Id(string(casted))
+		} else {
+			fieldCode = jen.Id(string(casted)).Add(extractType(env, fieldDesc.LoadInt(1)))
+			tagStart = 2
+		}
+	case *types.List:
+		if header, _ := casted.LoadInt(0).(types.Identifier); header == names.ListId {
+			fieldId, _ := casted.LoadInt(1).(types.Identifier)
+			fieldCode = jen.Id(string(fieldId)).Add(extractType(env, casted.LoadInt(2)))
+		} else {
+			fieldCode = extractType(env, casted)
+		}
+	}
+	if fieldCode == nil {
+		reportError(env, errTypeExpected)
+		return jen.Null()
+	}
+
+	tags := map[string]string{}
+	for index := tagStart; index < fieldDesc.Size(); index++ {
+		switch casted := fieldDesc.LoadInt(index).(type) {
+		case types.String:
+			if !splitTag(string(casted), tags) {
+				reportError(env, errStructTag)
+			}
+		case *types.List:
+			key, _ := casted.LoadInt(1).(types.Identifier)
+			value, ok := casted.LoadInt(2).(types.String)
+			if !ok || !isTag(casted) {
+				reportError(env, errStructTag)
+				continue
+			}
+			tags[string(key)] = string(value)
+		default:
+			reportError(env, errStructTag)
+		}
+	}
+	if len(tags) != 0 {
+		fieldCode.Tag(tags)
+	}
+	return fieldCode
+}
+
+// a field type can not be a string or a pair
+func isTag(object types.Object) bool {
+	switch casted := object.(type) {
+	case types.String:
+		return true
+	case *types.List:
+		header, _ := casted.LoadInt(0).(types.Identifier)
+		return header == names.ListId
+	}
+	return false
+}
+
+// read the key:"value" pairs of a Go struct tag (same format as reflect.StructTag)
+func splitTag(tag string, tags map[string]string) bool {
+	for tag = strings.TrimLeft(tag, " "); tag != ""; tag = strings.TrimLeft(tag, " ") {
+		key, rest, ok := strings.Cut(tag, ":")
+		if !ok || key == "" || strings.ContainsAny(key, " \"") {
+			return false
+		}
+
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil || quoted[0] != '"' {
+			return false
+		}
+
+		tags[key], _ = strconv.Unquote(quoted)
+		tag = rest[len(quoted):]
+	}
+	return true
+}
+
+// handle the elements of "interface[Name:func[params]results other]",
+// other is an embedded interface or a type constraint
+func extractMethods(env types.Environment, elems iter.Seq[types.Object]) []jen.Code {
+	var elemCodes []jen.Code
+	for elem := range elems {
+		if casted, ok := elem.(*types.List); ok {
+			if header, _ := casted.LoadInt(0).(types.Identifier); header == names.ListId {
+				methodId, _ := casted.LoadInt(1).(types.Identifier)
+				funcDesc, _ := casted.LoadInt(2).(*types.List)
+				params, _ := funcDesc.LoadInt(1).(*types.List)
+				returns, _ := funcDesc.LoadInt(2).(*types.List)
+				if params != nil {
+					if methodCode := extractSignature(env, jen.Id(string(methodId)), params, returns); methodCode != nil {
+						elemCodes = append(elemCodes, methodCode)
+					}
+				}
+				continue
+			}
+		}
+
+		if typeCode := extractType(env, elem); typeCode != nil {
+			elemCodes = append(elemCodes, typeCode)
+		}
+	}
+	return elemCodes
+}
+
 func extractNameOrQualified(env types.Environment, object types.Object) *jen.Statement {
 	switch casted := object.(type) {
 	case types.Identifier:
@@ -221,13 +434,49 @@ func extractGenType(env types.Environment, arg0 types.Object, arg1 types.Object)
 	return nil
 }
 
+// same as extractTypes, handle named result "(list name type)" too
+func extractResults(env types.Environment, results *types.List) ([]jen.Code, bool) {
+	next, stop := types.Pull(results.Iter())
+	defer stop()
+	next() // skip ListId
+
+	var resultCodes []jen.Code
+	for elem := range types.Push(next) {
+		var resultCode *jen.Statement
+		if isNamedResult(elem) {
+			casted, _ := elem.(*types.List)
+			nameId, _ := casted.LoadInt(1).(types.Identifier)
+			if typeCode := extractType(env, casted.LoadInt(2)); typeCode != nil {
+				resultCode = jen.Id(string(nameId)).Add(typeCode)
+			}
+		} else {
+			resultCode = extractType(env, elem)
+		}
+		if resultCode == nil {
+			return nil, false
+		}
+		resultCodes = append(resultCodes, resultCode)
+	}
+	return resultCodes, true
+}
+
+func isNamedResult(object types.Object) bool {
+	casted, ok := object.(*types.List)
+	if !ok {
+		return false
+	}
+
+	header, _ := casted.LoadInt(0).(types.Identifier)
+	return header == names.ListId
+}
+
 // skip first elem (should be ListId)
 func extractTypes(env types.Environment, typeIterable types.Iterable) ([]jen.Code, bool) {
 	next, stop := types.Pull(typeIterable.Iter())
 	defer stop()
 	next() // skip ListId
 
-	hasError := true
+	hasError := false
 	var typeCodes []jen.Code
 	for elem := range types.Push(next) {
 		typeCode := extractType(env, elem)
@@ -270,6 +519,8 @@ func extractAssignTargetFromList(env types.Environment, list *types.List) *jen.S
 			code.Index(compileToCode(env, elem)) // can not be slicing
 		}
 		return code
+	case names.GetId:
+		return jen.Add(compileToCode(env, list))
 	case names.StarId:
 		if list.Size() > 1 {
 			return jen.Op(string(op)).Add(extractAssignTarget(env, list.LoadInt(1)))
@@ -24,3 +24,9 @@ func init() {
 		}
 	}
 }
+
+// import path of a standard package from its name (ambiguous names are excluded)
+func LibraryPath(name string) (string, bool) {
+	path, ok := knownLibrary[name]
+	return path, ok
+}
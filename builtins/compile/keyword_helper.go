@@ -89,15 +89,15 @@ func extractReturnType(env types.Environment, object types.Object) (*jen.Stateme
 		return jen.Id(string(casted)), nil
 	case *types.List:
 		if head, _ := casted.LoadInt(0).(types.Identifier); head == names.ListId {
-			if typeCodes, ok := extractTypes(env, casted); ok {
-				return jen.Parens(jen.List(typeCodes...)), nil
+			if resultCodes, ok := extractResults(env, casted); ok {
+				return jen.Parens(jen.List(resultCodes...)), nil
 			}
 		} else {
 			if returnCode := extractType(env, object); returnCode != nil {
 				return returnCode, nil
 			}
 			// can not extract type, so object is the first instruction of the code block
-			return nil, []jen.Code{compileToCode(env, object)}
+			return nil, []jen.Code{compileToInstruction(env, object)}
 		}
 	}
 	return nil, nil
@@ -162,7 +162,7 @@ func processDef(env types.Environment, itArgs iter.Seq[types.Object], baseCode *
 		defCodes = processDefLines(env, types.Push(next), defCodes)
 		return wrapper{Renderer: baseCode.Defs(defCodes...)}
 	}
-	return wrappedErrorComment
+	return reportError(env, errDefinition)
 }
 
 // handle multiple "(name value)" or "(name:type)" or "(name:type value)"
@@ -191,16 +191,117 @@ func processDefLine(env types.Environment, defDesc *types.List) jen.Code {
 }
 
 // labellableCode is not cloned (must generate a new one on each call)
-func processLabellable(_ types.Environment, itArgs iter.Seq[types.Object], labellableCode *jen.Statement) types.Object {
+func processLabellable(env types.Environment, itArgs iter.Seq[types.Object], labellableCode *jen.Statement) types.Object {
 	next, stop := types.Pull(itArgs)
 	defer stop()
 
 	if arg0, ok := next(); ok {
 		labelId, ok := arg0.(types.Identifier)
 		if !ok {
-			return wrappedErrorComment
+			return reportError(env, errIdentifierType)
 		}
 		labellableCode.Id(string(labelId))
 	}
 	return wrapper{Renderer: labellableCode}
 }
+
+// keep the results of the function being compiled for the try form (the inference replaces "?"),
+// the returned function restore the previous ones (lambda could be nested)
+func storeResults(env types.Environment, results types.Object) func() {
+	previous, _ := env.LoadStr(hiddenResultsName)
+	env.StoreStr(hiddenResultsName, results)
+	return func() {
+		env.StoreStr(hiddenResultsName, previous)
+	}
+}
+
+// handle "(call context?)" and "(targets call context?)", the context is a string literal
+func extractTryParts(args *types.List) (types.Object, types.Object, types.String) {
+	var targets types.Object = types.None
+	start := 0
+	if _, isContext := args.LoadInt(1).(types.String); args.Size() > 1 && !isContext {
+		targets, start = args.LoadInt(0), 1
+	}
+
+	context, _ := args.LoadInt(start + 1).(types.String)
+	return targets, args.LoadInt(start), context
+}
+
+// zero values of the results except the last one (the error)
+func extractZeroResults(env types.Environment, results types.Object) ([]jen.Code, bool) {
+	var resultDescs []types.Object
+	switch casted := results.(type) {
+	case types.Identifier:
+		resultDescs = []types.Object{casted}
+	case *types.List:
+		if header, _ := casted.LoadInt(0).(types.Identifier); header != names.ListId {
+			resultDescs = []types.Object{casted}
+			break
+		}
+
+		for index := 1; index < casted.Size(); index++ {
+			resultDesc := casted.LoadInt(index)
+			if isNamedResult(resultDesc) {
+				resultDesc = resultDesc.(*types.List).LoadInt(2)
+			}
+			resultDescs = append(resultDescs, resultDesc)
+		}
+	}
+	if len(resultDescs) == 0 {
+		return nil, false
+	}
+
+	var zeroCodes []jen.Code
+	for _, resultDesc := range resultDescs[:len(resultDescs)-1] {
+		zeroCode := extractZeroValue(env, resultDesc)
+		if zeroCode == nil {
+			return nil, false
+		}
+		zeroCodes = append(zeroCodes, zeroCode)
+	}
+	return zeroCodes, true
+}
+
+// "*new(T)" when the kind of T is unknown (named type)
+func extractZeroValue(env types.Environment, object types.Object) *jen.Statement {
+	switch casted := object.(type) {
+	case types.Identifier:
+		switch casted {
+		case "any", "error":
+			return jen.Nil()
+		case "bool":
+			return jen.False()
+		case "string":
+			return jen.Lit("")
+		case "byte", "complex64", "complex128", names.Float32, names.Float64, names.Int, names.Int8, names.Int16,
+			names.Int32, names.Int64, "rune", names.Uint, names.Uint8, names.Uint16, names.Uint32, names.Uint64, "uintptr":
+			return jen.Lit(0)
+		}
+	case *types.List:
+		switch header, _ := casted.LoadInt(0).(types.Identifier); header {
+		case names.ArrowChanId, names.ChanArrowId, names.ChanId, names.FuncId, names.InterfaceId, names.MapId, names.StarId:
+			return jen.Nil()
+		case names.SliceId:
+			if casted.Size() == 2 { // not an array
+				return jen.Nil()
+			}
+		}
+	}
+
+	typeCode := extractType(env, object)
+	if typeCode == nil {
+		return nil
+	}
+	return jen.Op(string(names.StarId)).New(typeCode)
+}
+
+// the call of go and defer, a lambda alone is called without argument
+func compileToDeferred(env types.Environment, object types.Object) *jen.Statement {
+	code := jen.Add(compileToCode(env, object))
+	if list, ok := object.(*types.List); ok {
+		if header, _ := list.LoadInt(0).(types.Identifier); header == names.Lambda {
+			code.Call()
+		}
+	}
+	return code
+}
@@ -15,6 +15,8 @@ package compile
 
 import (
 	"iter"
+	"slices"
+	"strings"
 
 	"github.com/dave/jennifer/jen"
 	"github.com/dvaumoron/foresee/builtins/names"
@@ -28,13 +30,18 @@ func assertForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Obje
 	arg0, _ := next()
 	arg1, ok := next()
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errPairSize)
 	}
-	return wrapper{Renderer: compileToCode(env, arg0).Assert(extractType(env, arg1))}
+
+	typeCode := extractType(env, arg1)
+	if typeCode == nil {
+		return reportError(env, errTypeExpected)
+	}
+	return wrapper{Renderer: compileToCode(env, arg0).Assert(typeCode)}
 }
 
 func blockForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	intructionCodes := compileToCodeSlice(env, itArgs)
+	intructionCodes := compileToInstructions(env, itArgs)
 	return wrapper{Renderer: jen.Block(intructionCodes...)}
 }
 
@@ -51,10 +58,10 @@ func caseForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 		condCodes = extractValueOrMultiple(env, arg0)
 	}
 	if len(condCodes) == 0 {
-		return wrappedErrorComment
+		return reportError(env, errCondition)
 	}
 
-	instructionCodes := compileToCodeSlice(env, types.Push(next))
+	instructionCodes := compileToInstructions(env, types.Push(next))
 	return wrapper{Renderer: jen.Case(condCodes...).Add(instructionCodes...)}
 }
 
@@ -63,15 +70,15 @@ func constForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Objec
 }
 
 func defaultForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	intructionCodes := compileToCodeSlice(env, itArgs)
+	intructionCodes := compileToInstructions(env, itArgs)
 	return wrapper{Renderer: jen.Default().Add(intructionCodes...)}
 }
 
 func deferForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
 	for arg0 := range itArgs {
-		return wrapper{Renderer: jen.Defer().Add(compileToCode(env, arg0))}
+		return wrapper{Renderer: jen.Defer().Add(compileToDeferred(env, arg0))}
 	}
-	return wrappedErrorComment
+	return reportError(env, errUnarySize)
 }
 
 func continueForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
@@ -87,12 +94,13 @@ func fileForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 	env.StoreStr(hiddenPackageName, mainId)
 	env.StoreStr(hiddenImportsName, types.MakeBaseEnvironment())
 
-	codes := compileToCodeSlice(env, itArgs)
+	codes := compileToDeclarations(env, itArgs)
 
 	packageName, _ := env.LoadStr(hiddenPackageName)
 	packageNameId, _ := packageName.(types.Identifier)
 
 	jenFile := jen.NewFile(string(packageNameId))
+	jenFile.HeaderComment(GeneratedHeader)
 	jenFile.Add(codes...)
 
 	imports, _ := env.LoadStr(hiddenImportsName)
@@ -134,10 +142,10 @@ func forForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 			}
 		}
 	default:
-		return wrappedErrorComment
+		return reportError(env, errLoopHeader)
 	}
 
-	instructionCodes := compileToCodeSlice(env, types.Push(next))
+	instructionCodes := compileToInstructions(env, types.Push(next))
 	return wrapper{Renderer: jen.For(condCodes...).Block(instructionCodes...)}
 }
 
@@ -150,7 +158,7 @@ func funcForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 	if nameCode := extractNameWithGenericDef(env, arg0); nameCode == nil {
 		casted, ok := arg0.(*types.List)
 		if !ok {
-			return wrappedErrorComment
+			return reportError(env, errFuncName)
 		}
 
 		var receiverCode *jen.Statement
@@ -171,18 +179,19 @@ func funcForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 	params, _ := next()
 	paramCodes, ok := extractParameter(env, params)
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errParameterList)
 	}
 
 	funcCode.Params(paramCodes...)
 
 	argN, _ := next()
+	defer storeResults(env, argN)()
 	returnCode, instructionCodes := extractReturnType(env, argN)
 	if returnCode != nil {
 		funcCode.Add(returnCode)
 	}
 
-	instructionCodesTemp := compileToCodeSlice(env, types.Push(next))
+	instructionCodesTemp := compileToInstructions(env, types.Push(next))
 	instructionCodes = append(instructionCodes, instructionCodesTemp...)
 	return wrapper{Renderer: funcCode.Block(instructionCodes...).Line()}
 }
@@ -194,11 +203,24 @@ func genTypeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Obj
 	arg0, _ := next()
 	arg1, ok := next()
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errPairSize)
 	}
 	return literalWrapper{Renderer: extractGenType(env, arg0, arg1)}
 }
 
+// "(inst F (list T...))" is "F[T...]", appliable as a call (unlike gen)
+func instantiateForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	next, stop := types.Pull(itArgs)
+	defer stop()
+
+	arg0, _ := next()
+	arg1, ok := next()
+	if !ok {
+		return reportError(env, errPairSize)
+	}
+	return callableWrapper{Renderer: extractGenType(env, arg0, arg1)}
+}
+
 func getForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
 	next, stop := types.Pull(itArgs)
 	defer stop()
@@ -207,7 +229,7 @@ func getForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 	arg1, _ := next()
 	fieldId, ok := arg1.(types.Identifier)
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errIdentifierType)
 	}
 
 	getCode := extractQualified(env, arg0, arg1)
@@ -226,9 +248,9 @@ func getForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 
 func goForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
 	for arg0 := range itArgs {
-		return wrapper{Renderer: jen.Go().Add(compileToCode(env, arg0))}
+		return wrapper{Renderer: jen.Go().Add(compileToDeferred(env, arg0))}
 	}
-	return wrappedErrorComment
+	return reportError(env, errUnarySize)
 }
 
 func gotoForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
@@ -238,7 +260,7 @@ func gotoForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 		}
 		break
 	}
-	return wrappedErrorComment
+	return reportError(env, errIdentifierType)
 }
 
 func ifForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
@@ -249,7 +271,7 @@ func ifForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
 	arg1, _ := next()
 	instruction1, ok := arg1.(*types.List)
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errBlockType)
 	}
 
 	var condCodes []jen.Code
@@ -264,27 +286,27 @@ func ifForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
 		}
 	}
 	if len(condCodes) == 0 {
-		return wrappedErrorComment
+		return reportError(env, errCondition)
 	}
 
 	ifCode := jen.If(condCodes...)
 	if header, _ := instruction1.LoadInt(0).(types.Identifier); header == names.Block {
 		ifCode.Add(compileToCode(env, arg1))
 	} else {
-		ifCode.Block(compileToCode(env, arg1))
+		ifCode.Block(compileToInstruction(env, arg1))
 	}
 
 	if arg2, ok := next(); ok {
 		instruction2, ok := arg2.(*types.List)
 		if !ok {
-			return wrappedErrorComment
+			return reportError(env, errBlockType)
 		}
 
 		ifCode.Else()
 		if header, _ := instruction2.LoadInt(0).(types.Identifier); header == names.Block {
 			ifCode.Add(compileToCode(env, arg2))
 		} else {
-			ifCode.Block(compileToCode(env, arg2))
+			ifCode.Block(compileToInstruction(env, arg2))
 		}
 	}
 	return wrapper{Renderer: ifCode}
@@ -301,7 +323,7 @@ func importForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Obje
 		switch casted := importDesc.(type) {
 		case *types.List:
 			if casted.Size() > 1 {
-				packageId, _ := casted.LoadInt(1).(types.Identifier)
+				packageId, _ := casted.LoadInt(0).(types.Identifier)
 				castedImport.StoreStr(string(packageId), casted.LoadInt(1))
 			} else {
 				path, _ := casted.LoadInt(0).(types.String)
@@ -319,6 +341,10 @@ func importForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Obje
 	return types.None
 }
 
+func interfaceTypeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	return callableWrapper{Renderer: jen.Interface(extractMethods(env, itArgs)...)}
+}
+
 func labelForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
 	for arg0 := range itArgs {
 		if labelId, ok := arg0.(types.Identifier); ok {
@@ -326,7 +352,7 @@ func labelForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Objec
 		}
 		break
 	}
-	return wrappedErrorComment
+	return reportError(env, errIdentifierType)
 }
 
 func lambdaForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
@@ -336,27 +362,32 @@ func lambdaForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Obje
 	arg0, _ := next()
 	paramCodes, ok := extractParameter(env, arg0)
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errParameterList)
 	}
 
 	funcCode := jen.Func().Params(paramCodes...)
 
 	arg1, _ := next()
+	defer storeResults(env, arg1)()
 	returnCode, instructionCodes := extractReturnType(env, arg1)
 	if returnCode != nil {
 		funcCode.Add(returnCode)
 	}
 
-	instructionCodesTemp := compileToCodeSlice(env, types.Push(next))
+	instructionCodesTemp := compileToInstructions(env, types.Push(next))
 	instructionCodes = append(instructionCodes, instructionCodesTemp...)
 	return callableWrapper{Renderer: funcCode.Block(instructionCodes...)}
 }
 
 func literalForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
 	for arg0 := range itArgs {
-		return literalWrapper{Renderer: extractType(env, arg0)}
+		typeCode := extractType(env, arg0)
+		if typeCode == nil {
+			return reportError(env, errTypeExpected)
+		}
+		return literalWrapper{Renderer: typeCode}
 	}
-	return wrappedErrorComment
+	return reportError(env, errUnarySize)
 }
 
 func mapTypeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
@@ -366,7 +397,7 @@ func mapTypeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Obj
 	arg0, _ := next()
 	arg1, ok := next()
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errPairSize)
 	}
 	return literalWrapper{Renderer: jen.Map(extractType(env, arg0)).Add(extractType(env, arg1))}
 }
@@ -388,7 +419,7 @@ func rangeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Objec
 	for arg0 := range itArgs {
 		return wrapper{Renderer: jen.Range().Add(compileToCode(env, arg0))}
 	}
-	return wrappedErrorComment
+	return reportError(env, errUnarySize)
 }
 
 func returnForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
@@ -410,18 +441,22 @@ func sliceOrArrayTypeForm(env types.Environment, itArgs iter.Seq[types.Object])
 	if ok {
 		typeCode := extractArrayType(env, arg0, arg1)
 		if typeCode == nil {
-			return wrappedErrorComment
+			return reportError(env, errTypeExpected)
 		}
 		return literalWrapper{Renderer: typeCode}
 	}
 
 	typeCode := extractType(env, arg0)
 	if typeCode == nil {
-		return wrappedErrorComment
+		return reportError(env, errTypeExpected)
 	}
 	return literalWrapper{Renderer: jen.Index().Add(typeCode)}
 }
 
+func structTypeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	return literalWrapper{Renderer: jen.Struct(extractFields(env, itArgs)...)}
+}
+
 func switchForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
 	next, stop := types.Pull(itArgs)
 	defer stop()
@@ -435,6 +470,56 @@ func switchForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Obje
 	return wrapper{Renderer: jen.Switch(condCodes...).Block(caseCodes...)}
 }
 
+// handle "(try call context?)" and "(try targets call context?)",
+// an error ends the function with the zero values of its other results
+func tryForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	args := types.NewList().AddAll(itArgs)
+	if args.Size() == 0 {
+		return reportError(env, errUnarySize)
+	}
+
+	targets, call, context := extractTryParts(args)
+	results, _ := env.LoadStr(hiddenResultsName)
+	returnCodes, ok := extractZeroResults(env, results)
+	if !ok {
+		return reportError(env, errTryResults)
+	}
+
+	errCode := jen.Err()
+	if context != "" {
+		format := strings.ReplaceAll(string(context), "%", "%%") + ": %w"
+		errCode = jen.Qual("fmt", "Errorf").Call(jen.Lit(format), jen.Err())
+	}
+	returnCode := jen.Return(append(returnCodes, errCode)...)
+
+	var targetIds []types.Object
+	switch casted := targets.(type) {
+	case types.Identifier:
+		targetIds = []types.Object{casted}
+	case *types.List:
+		targetIds = slices.Collect(casted.Iter())
+	}
+
+	declaring := false
+	targetCodes := make([]jen.Code, 0, len(targetIds)+1)
+	for _, targetId := range targetIds {
+		casted, ok := targetId.(types.Identifier)
+		if !ok {
+			return reportError(env, errIdentifierType)
+		}
+		declaring = declaring || casted != "_"
+		targetCodes = append(targetCodes, jen.Id(string(casted)))
+	}
+
+	initCode := jen.List(append(targetCodes, jen.Err())...).Op(names.DeclareAssign).Add(compileToCode(env, call))
+	checkCode := jen.Err().Op(names.NotEqual).Nil()
+	if !declaring {
+		// the error is scoped to the check
+		return wrapper{Renderer: jen.If(initCode, checkCode).Block(returnCode)}
+	}
+	return wrapper{Renderer: initCode.Line().If(checkCode).Block(returnCode)}
+}
+
 func typeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
 	next, stop := types.Pull(itArgs)
 	defer stop()
@@ -442,7 +527,7 @@ func typeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 	arg0, _ := next()
 	arg1, ok := next()
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errPairSize)
 	}
 
 	typeCode := jen.Type().Add(extractNameWithGenericDef(env, arg0))
@@ -450,7 +535,7 @@ func typeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 	switch oldType := arg1.(type) {
 	case types.Identifier:
 		switch oldType {
-		case names.Interface:
+		case names.InterfaceId:
 			var defCodes []jen.Code
 			for elem := range types.Push(next) {
 				casted, _ := elem.(*types.List)
@@ -512,31 +597,12 @@ func typeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 				}
 			}
 			typeCode.Interface(defCodes...)
-		case names.Struct:
+		case names.StructId:
 			var defCodes []jen.Code
 			for elem := range types.Push(next) {
-				casted, _ := elem.(*types.List)
-				castedSize := casted.Size()
-				if castedSize == 1 {
-					// nested type
-					defCodes = append(defCodes, extractType(env, casted.LoadInt(0)))
-					continue
-				}
-
-				fieldId, _ := casted.LoadInt(0).(types.Identifier)
-				defCode := jen.Id(string(fieldId)).Add(extractType(env, casted.LoadInt(1)))
-				if castedSize > 2 {
-					itemList, _ := elem.(*types.List)
-					items := map[string]string{}
-					for item := range itemList.Iter() {
-						casted, _ := item.(*types.List)
-						key := casted.LoadInt(1).(types.String)
-						value := casted.LoadInt(2).(types.String)
-						items[string(key)] = string(value)
-					}
-					defCode.Tag(items)
+				if fieldDesc, ok := elem.(*types.List); ok {
+					defCodes = append(defCodes, extractFieldDecl(env, fieldDesc))
 				}
-				defCodes = append(defCodes, defCode)
 			}
 			typeCode.Struct(defCodes...)
 		default:
@@ -545,7 +611,7 @@ func typeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 	case *types.List:
 		typeCode.Add(extractTypeFromList(env, oldType))
 	default:
-		return wrappedErrorComment
+		return reportError(env, errTypeDef)
 	}
 	return wrapper{Renderer: typeCode.Line()}
 }
@@ -41,6 +41,10 @@ func processAssign(env types.Environment, itArgs iter.Seq[types.Object], op stri
 
 	arg0, _ := next()
 	values := compileToCodeSlice(env, types.Push(next))
+	if len(values) == 0 {
+		return reportError(env, errPairSize)
+	}
+
 	switch casted := arg0.(type) {
 	case types.Identifier:
 		return wrapper{Renderer: jen.Id(string(casted)).Op(op).Add(values[0])}
@@ -55,7 +59,7 @@ func processAssign(env types.Environment, itArgs iter.Seq[types.Object], op stri
 		}
 		return wrapper{Renderer: jen.List(ids...).Op(op).List(values...)}
 	}
-	return wrappedErrorComment
+	return reportError(env, errAssignableType)
 }
 
 func processAugmentedAssign(env types.Environment, itArgs iter.Seq[types.Object], opAssign string) types.Object {
@@ -66,7 +70,7 @@ func processAugmentedAssign(env types.Environment, itArgs iter.Seq[types.Object]
 	arg1, ok := next()
 	targetCode := extractAssignTarget(env, arg0)
 	if !ok || targetCode == nil {
-		return wrappedErrorComment
+		return reportError(env, errAssignableType)
 	}
 	return wrapper{Renderer: targetCode.Op(opAssign).Add(compileToCode(env, arg1))}
 }
@@ -79,7 +83,7 @@ func processAugmentedAssignMore(env types.Environment, itArgs iter.Seq[types.Obj
 	arg1, ok := next()
 	targetCode := extractAssignTarget(env, arg0)
 	if !ok || targetCode == nil {
-		return wrappedErrorComment
+		return reportError(env, errAssignableType)
 	}
 
 	targetCode.Op(opAssign).Add(compileToCode(env, arg1))
@@ -96,7 +100,7 @@ func processBinaryMoreOperator(env types.Environment, itArgs iter.Seq[types.Obje
 	arg0, _ := next()
 	arg1, ok := next()
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errPairSize)
 	}
 
 	binaryCode := compileToCode(env, arg0).Op(op).Add(compileToCode(env, arg1))
@@ -113,7 +117,7 @@ func processBinaryOperator(env types.Environment, itArgs iter.Seq[types.Object],
 	arg0, _ := next()
 	arg1, ok := next()
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errPairSize)
 	}
 	return wrapper{Renderer: compileToCode(env, arg0).Op(op).Add(compileToCode(env, arg1))}
 }
@@ -125,7 +129,7 @@ func processComparison(env types.Environment, itArgs iter.Seq[types.Object], op
 	arg0, _ := next()
 	arg1, ok := next()
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errPairSize)
 	}
 
 	argCode := jen.Code(compileToCode(env, arg1))
@@ -141,7 +145,7 @@ func processUnaryPostOperator(env types.Environment, itArgs iter.Seq[types.Objec
 	for arg0 := range itArgs {
 		return wrapper{Renderer: compileToCode(env, arg0).Op(op)}
 	}
-	return wrappedErrorComment
+	return reportError(env, errUnarySize)
 }
 
 func processUnaryOrBinaryMoreOperator(env types.Environment, itArgs iter.Seq[types.Object], op string) types.Object {
@@ -150,7 +154,7 @@ func processUnaryOrBinaryMoreOperator(env types.Environment, itArgs iter.Seq[typ
 
 	arg0, ok := next()
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errUnarySize)
 	}
 
 	valueCodesTemp := compileToCodeSlice(env, types.Push(next))
@@ -77,7 +77,7 @@ func callMethodForm(env types.Environment, itArgs iter.Seq[types.Object]) types.
 	arg1, _ := next()
 	methodId, ok := arg1.(types.Identifier)
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errIdentifierType)
 	}
 
 	argsCode := compileToCodeSlice(env, types.Push(next))
@@ -113,7 +113,7 @@ func extendSliceForm(env types.Environment, itArgs iter.Seq[types.Object]) types
 	for arg0 := range itArgs {
 		return wrapper{Renderer: compileToCode(env, arg0).Op(string(names.EllipsisId))}
 	}
-	return wrappedErrorComment
+	return reportError(env, errUnarySize)
 }
 
 func greaterForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
@@ -135,7 +135,7 @@ func indexOrSliceForm(env types.Environment, itArgs iter.Seq[types.Object]) type
 	arg0, _ := next()
 	arg1, ok := next()
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errPairSize)
 	}
 
 	slice0 := extractSliceIndexes(env, arg1)
@@ -180,7 +180,7 @@ func notForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 	for arg0 := range itArgs {
 		return wrapper{Renderer: jen.Op(string(names.NotId)).Add(compileToCode(env, arg0))}
 	}
-	return wrappedErrorComment
+	return reportError(env, errUnarySize)
 }
 
 func notEqualForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
@@ -197,7 +197,7 @@ func receivingOrSendingForm(env types.Environment, itArgs iter.Seq[types.Object]
 
 	arg0, ok := next()
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errUnarySize)
 	}
 
 	arg1, ok := next()
@@ -228,7 +228,7 @@ func storeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Objec
 	arg0, _ := next()
 	arg1, ok := next()
 	if !ok {
-		return wrappedErrorComment
+		return reportError(env, errTripleSize)
 	}
 
 	slice0 := extractSliceIndexes(env, arg1)
@@ -238,11 +238,15 @@ func storeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Objec
 	}
 
 	lastIndex := len(slices) - 1
+	if lastIndex < 0 {
+		return reportError(env, errTripleSize)
+	}
+
 	slicingCode := compileToCode(env, arg0).Index(slice0...)
 	for index := 0; index < lastIndex; index++ {
 		slicingCode.Index(slices[index]...)
 	}
-	return wrapper{Renderer: slicingCode.Op(names.Equal).Add(slices[lastIndex][0])}
+	return wrapper{Renderer: slicingCode.Op(names.Assign).Add(slices[lastIndex][0])}
 }
 
 func substractAssignForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
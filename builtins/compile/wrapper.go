@@ -18,6 +18,7 @@ import (
 
 	"github.com/dave/jennifer/jen"
 	"github.com/dvaumoron/foresee/builtins/names"
+	"github.com/dvaumoron/foresee/diagnostic"
 	"github.com/dvaumoron/foresee/types"
 )
 
@@ -87,7 +88,7 @@ func (w literalWrapper) Apply(env types.Environment, itArgs iter.Seq[types.Objec
 			// detect Field:value (could be a classic function/operator call)
 			if header, _ := casted2.LoadInt(0).(types.Identifier); header == names.ListId {
 				dict := jen.Dict{compileToCode(env, casted2.LoadInt(1)): compileToCode(env, casted2.LoadInt(2))}
-				for elem := range types.Push(next){
+				for elem := range types.Push(next) {
 					fieldDesc, _ := elem.(*types.List)
 					dict[compileToCode(env, fieldDesc.LoadInt(1))] = compileToCode(env, fieldDesc.LoadInt(2))
 				}
@@ -108,6 +109,12 @@ func (w literalWrapper) Apply(env types.Environment, itArgs iter.Seq[types.Objec
 // (the wrapper is a function call form appliable)
 type compileEnvironment struct {
 	types.Environment
+	diagnostics *diagnostic.Collector
+	current     *types.Span // span of the form being compiled
+}
+
+func makeCompileEnvironment(env types.Environment, diagnostics *diagnostic.Collector, span types.Span) compileEnvironment {
+	return compileEnvironment{Environment: env, diagnostics: diagnostics, current: &span}
 }
 
 func (c compileEnvironment) LoadStr(key string) (types.Object, bool) {
@@ -121,3 +128,26 @@ func (c compileEnvironment) LoadStr(key string) (types.Object, bool) {
 func (c compileEnvironment) Load(key types.Object) types.Object {
 	return types.Load(c, key)
 }
+
+// mark span as the position of the form being compiled,
+// the returned function restore the previous position
+func locate(env types.Environment, span types.Span) func() {
+	casted, ok := env.(compileEnvironment)
+	if !ok || !span.IsValid() {
+		return func() {}
+	}
+
+	previous := *casted.current
+	*casted.current = span
+	return func() {
+		*casted.current = previous
+	}
+}
+
+// record err at the position of the form being compiled and return a placeholder
+func reportError(env types.Environment, err error) types.Object {
+	if casted, ok := env.(compileEnvironment); ok {
+		casted.diagnostics.AddError(*casted.current, err)
+	}
+	return wrappedErrorComment
+}
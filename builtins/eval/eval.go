@@ -24,14 +24,20 @@ import (
 const (
 	// user can not directly use this kind of id (# start a comment)
 	hiddenTypesName = "#types"
+	// generator of fresh identifiers (for macro hygiene)
+	hiddenSymbolsName = "#symbols"
+	// identifiers renamed during the current macro expansion
+	hiddenRenamingName = "#renaming"
+	// label of the next loop or switch
+	hiddenLabelName = "#label"
 )
 
 var Builtins = initBuitins()
 
 func initBuitins() types.BaseEnvironment {
-	assignAppliable := types.MakeNativeAppliable(assignForm)
 	literalAppliable := types.MakeNativeAppliable(literalForm)
 	noOpAppliable := types.MakeNativeAppliable(noOp)
+	unquoteAppliable := types.MakeNativeAppliable(unquoteForm)
 
 	base := types.MakeBaseEnvironment()
 	base.StoreStr(names.AddAssign, types.MakeNativeAppliable(sumSetForm))
@@ -43,7 +49,7 @@ func initBuitins() types.BaseEnvironment {
 	base.StoreStr(names.Append, types.MakeNativeAppliable(appendForm))
 	base.StoreStr(names.Arrow, types.MakeNativeAppliable(receivingOrSendingForm))
 	base.StoreStr(names.Assert, types.MakeNativeAppliable(assertForm))
-	base.StoreStr(names.Assign, assignAppliable)
+	base.StoreStr(names.Assign, types.MakeNativeAppliable(assignForm))
 	base.StoreStr(names.Block, types.MakeNativeAppliable(blockForm))
 	base.StoreStr(names.Break, types.MakeNativeAppliable(breakForm))
 	base.StoreStr(names.Cap, types.MakeNativeAppliable(capForm))
@@ -52,7 +58,7 @@ func initBuitins() types.BaseEnvironment {
 	base.StoreStr(names.Close, types.MakeNativeAppliable(closeForm))
 	base.StoreStr(names.Const, types.MakeNativeAppliable(constForm))
 	base.StoreStr(names.Continue, types.MakeNativeAppliable(continueForm))
-	base.StoreStr(names.DeclareAssign, assignAppliable)
+	base.StoreStr(names.DeclareAssign, types.MakeNativeAppliable(declareAssignForm))
 	base.StoreStr(names.Decrement, types.MakeNativeAppliable(decrementForm))
 	base.StoreStr(names.Default, types.MakeNativeAppliable(defaultForm))
 	base.StoreStr(names.Defer, types.MakeNativeAppliable(deferForm))
@@ -68,6 +74,7 @@ func initBuitins() types.BaseEnvironment {
 	base.StoreStr(names.For, types.MakeNativeAppliable(forForm))
 	base.StoreStr(string(names.FuncId), types.MakeNativeAppliable(funcForm))
 	base.StoreStr(string(names.GenId), literalAppliable)
+	base.StoreStr(names.Gensym, types.MakeNativeAppliable(gensymFunc))
 	base.StoreStr(string(names.GetId), types.MakeNativeAppliable(getForm))
 	base.StoreStr(names.Go, types.MakeNativeAppliable(goForm))
 	base.StoreStr(names.Goto, types.MakeNativeAppliable(gotoForm))
@@ -81,6 +88,7 @@ func initBuitins() types.BaseEnvironment {
 	base.StoreStr(names.Int16, types.MakeNativeAppliable(intConvFunc))
 	base.StoreStr(names.Int32, types.MakeNativeAppliable(intConvFunc))
 	base.StoreStr(names.Int64, types.MakeNativeAppliable(intConvFunc))
+	base.StoreStr(string(names.InterfaceId), types.MakeNativeAppliable(interfaceTypeForm))
 	base.StoreStr(names.Label, types.MakeNativeAppliable(labelForm))
 	base.StoreStr(names.Lambda, types.MakeNativeAppliable(lambdaForm))
 	base.StoreStr(names.Len, types.MakeNativeAppliable(lenForm))
@@ -106,23 +114,30 @@ func initBuitins() types.BaseEnvironment {
 	base.StoreStr(names.Percent, types.MakeNativeAppliable(remainderFunc))
 	base.StoreStr(names.Pipe, types.MakeNativeAppliable(bitwiseXOrFunc))
 	base.StoreStr(names.Plus, types.MakeNativeAppliable(sumFunc))
+	base.StoreStr(string(names.QuasiquoteId), types.MakeNativeAppliable(quasiquoteForm))
+	base.StoreStr(names.Quote, types.MakeNativeAppliable(quoteForm))
 	base.StoreStr(names.Range, types.MakeNativeAppliable(rangeForm))
 	base.StoreStr(names.Return, types.MakeNativeAppliable(returnForm))
 	base.StoreStr(names.RShift, types.MakeNativeAppliable(rightShiftFunc))
 	base.StoreStr(names.RShiftAssign, types.MakeNativeAppliable(rightShiftAssignForm))
+	base.StoreStr(names.Rule, types.MakeNativeAppliable(ruleForm))
 	base.StoreStr(names.Select, types.MakeNativeAppliable(selectForm))
 	base.StoreStr(names.Slash, types.MakeNativeAppliable(divideFunc))
 	base.StoreStr(string(names.SliceId), types.MakeNativeAppliable(sliceOrArrayTypeForm))
 	base.StoreStr(string(names.StarId), types.MakeNativeAppliable(dereferenceOrMultiplyForm))
 	base.StoreStr(string(names.StoreId), types.MakeNativeAppliable(storeForm))
+	base.StoreStr(string(names.StructId), types.MakeNativeAppliable(structTypeForm))
 	base.StoreStr(names.SubAssign, types.MakeNativeAppliable(minusSetForm))
 	base.StoreStr(names.Switch, types.MakeNativeAppliable(switchForm))
+	base.StoreStr(names.Try, types.MakeNativeAppliable(tryForm))
 	base.StoreStr(names.Type, types.MakeNativeAppliable(typeForm))
 	base.StoreStr(names.Uint, types.MakeNativeAppliable(intConvFunc))
 	base.StoreStr(names.Uint8, types.MakeNativeAppliable(intConvFunc))
 	base.StoreStr(names.Uint16, types.MakeNativeAppliable(intConvFunc))
 	base.StoreStr(names.Uint32, types.MakeNativeAppliable(intConvFunc))
 	base.StoreStr(names.Uint64, types.MakeNativeAppliable(intConvFunc))
+	base.StoreStr(string(names.UnquoteId), unquoteAppliable)
+	base.StoreStr(string(names.UnquoteSplicingId), unquoteAppliable)
 	base.StoreStr(names.Var, types.MakeNativeAppliable(varForm))
 	base.StoreStr(names.XorAssign, types.MakeNativeAppliable(bitwiseXOrAssignForm))
 
@@ -15,6 +15,7 @@ package eval
 
 import (
 	"errors"
+	"fmt"
 	"iter"
 
 	"github.com/dvaumoron/foresee/builtins/names"
@@ -23,14 +24,19 @@ import (
 
 var (
 	errAssignableType = errors.New("wait assignable type")
+	errBuiltinAssign  = errors.New("can not assign a builtin")
+	errClauseType     = errors.New("wait case or default clause")
 	errConversion     = errors.New("uncompatible for conversion")
+	errFieldCount     = errors.New("wait at most one value by field")
 	errIndexableType  = errors.New("wait indexable type")
 	errPairSize       = errors.New("wait at least 2 elements")
+	errPatternType    = errors.New("wait identifier or string in pattern")
 	errSelectableType = errors.New("wait indexable type")
 	errTripleSize     = errors.New("wait at least 3 elements")
 	errUnarySize      = errors.New("wait 1 argument")
 	errUnimplemented  = errors.New("feature unimplemented in eval mode")
 	errUnknownField   = errors.New("field or method unknown")
+	errUnquote        = errors.New("wait unquote inside quasiquote")
 )
 
 func evalIterator(it iter.Seq[types.Object], env types.Environment) iter.Seq[types.Object] {
@@ -47,8 +53,11 @@ func evalIterator(it iter.Seq[types.Object], env types.Environment) iter.Seq[typ
 func buildAssignFunc(env types.Environment, object types.Object) func(types.Object) {
 	switch casted := object.(type) {
 	case types.Identifier:
+		if casted == "_" {
+			return ignoreValue
+		}
 		return func(value types.Object) {
-			env.StoreStr(string(casted), value)
+			updateStr(env, string(casted), value)
 		}
 	case *types.List:
 		return buildAssignFuncFromList(env, casted)
@@ -133,7 +142,7 @@ func buildAssignFuncFromList(env types.Environment, list *types.List) func(types
 			storable.Store(index, value)
 		}
 	case names.StarId:
-		if size != 1 {
+		if size != 2 {
 			panic(errUnarySize)
 		}
 
@@ -142,3 +151,27 @@ func buildAssignFuncFromList(env types.Environment, list *types.List) func(types
 
 	return nil
 }
+
+// same as buildAssignFunc, except identifiers are declared in env
+func buildDeclareFunc(env types.Environment, object types.Object) func(types.Object) {
+	if id, ok := object.(types.Identifier); ok && id != "_" {
+		return func(value types.Object) {
+			env.StoreStr(string(id), value)
+		}
+	}
+	return buildAssignFunc(env, object)
+}
+
+func ignoreValue(types.Object) {}
+
+// store value in the scope declaring name (in env when name is unknown),
+// the builtins are shared between files so they are not assignable
+func updateStr(env types.Environment, name string, value types.Object) {
+	if updatable, ok := env.(types.Updatable); ok && updatable.UpdateStr(name, value) {
+		return
+	}
+	if _, ok := env.LoadStr(name); ok {
+		panic(fmt.Errorf("%w : %s", errBuiltinAssign, name))
+	}
+	env.StoreStr(name, value)
+}
@@ -15,12 +15,16 @@ package eval
 
 import (
 	"iter"
+	"strings"
 
 	"github.com/dvaumoron/foresee/builtins/names"
 	"github.com/dvaumoron/foresee/types"
 )
 
-var initOrConvertMapAppliable = types.MakeNativeAppliable(initOrConverMapForm)
+var (
+	identityConvAppliable     = types.MakeNativeAppliable(identityConvForm)
+	initOrConvertMapAppliable = types.MakeNativeAppliable(initOrConverMapForm)
+)
 
 func copyStruct(env types.Environment, src iter.Seq[types.Object], typeName string) types.Object {
 	return initFromPairs[types.Environment](env, src, makeDynamicObject(env, typeName), 2, copyPairAdder)
@@ -38,6 +42,19 @@ func extractTypeName(o types.Object) string {
 	panic(errIdentifierType)
 }
 
+// handle "(list name type)" and embedded type (named by its type name)
+func extractFieldName(o types.Object) string {
+	if casted, ok := o.(*types.List); ok {
+		switch header, _ := casted.LoadInt(0).(types.Identifier); header {
+		case names.ListId, names.StarId, names.GenId:
+			return extractFieldName(casted.LoadInt(1))
+		case names.GetId:
+			return extractFieldName(casted.LoadInt(2))
+		}
+	}
+	return extractTypeName(o)
+}
+
 func initFromPairs[T types.Object](env types.Environment, itArgs iter.Seq[types.Object], o T, tooSmallSize int, pairAdder func(T, *types.List, types.Environment)) types.Object {
 	for elem := range itArgs {
 		pair, ok := elem.(*types.List)
@@ -93,6 +110,56 @@ func mapPairAdder(res types.Storable, pair *types.List, env types.Environment) {
 	res.Store(pair.LoadInt(1).Eval(env), pair.LoadInt(2).Eval(env))
 }
 
+// copy o, replacing (unquote a) with the value of a,
+// inserting the elements of the value of b for (unquote-splicing b)
+// and the new name of the identifiers from renamed
+func quasiquoteObject(env types.Environment, o types.Object, renamed renaming) types.Object {
+	switch casted := o.(type) {
+	case types.Identifier:
+		if newName, ok := renamed.names[string(casted)]; ok {
+			return newName
+		}
+		return o
+	case *types.List:
+		switch header, _ := casted.LoadInt(0).(types.Identifier); header {
+		case names.UnquoteId:
+			return casted.LoadInt(1).Eval(env)
+		case names.UnquoteSplicingId:
+			panic(errUnquote)
+		}
+
+		// field and method names are not renamed
+		selectorStart := casted.Size()
+		switch header, _ := casted.LoadInt(0).(types.Identifier); header {
+		case names.GetId, names.Dot:
+			selectorStart = 2
+		}
+
+		res := types.NewList().SetSpan(casted.Span())
+		for index := range casted.Size() {
+			elemRenamed := renamed
+			if index >= selectorStart {
+				elemRenamed = renaming{}
+			}
+
+			elem := casted.LoadInt(index)
+			if elemList, ok := elem.(*types.List); ok {
+				if header, _ := elemList.LoadInt(0).(types.Identifier); header == names.UnquoteSplicingId {
+					spliced, ok := elemList.LoadInt(1).Eval(env).(types.Iterable)
+					if !ok {
+						panic(errListType)
+					}
+					res.AddAll(spliced.Iter())
+					continue
+				}
+			}
+			res.AddWithSpan(quasiquoteObject(env, elem, elemRenamed), casted.SpanInt(index))
+		}
+		return res
+	}
+	return o
+}
+
 func structPairAdder(res types.Environment, pair *types.List, env types.Environment) {
 	id, ok := pair.LoadInt(1).(types.Identifier)
 	if !ok {
@@ -101,3 +168,302 @@ func structPairAdder(res types.Environment, pair *types.List, env types.Environm
 
 	res.StoreStr(string(id), pair.LoadInt(2).Eval(env))
 }
+
+// "(init... cond)" evaluate init in env (the local scope of the statement) and return the condition
+func evalHeader(env types.Environment, header types.Object) types.Object {
+	if casted, ok := header.(*types.List); ok {
+		if _, ok := casted.LoadInt(0).(*types.List); ok {
+			last := casted.Size() - 1
+			for index := range last {
+				casted.LoadInt(index).Eval(env)
+			}
+			return casted.LoadInt(last).Eval(env)
+		}
+	}
+	return header.Eval(env)
+}
+
+// a branch is a block or a single statement
+func evalBranch(env types.Environment, branch types.Object) types.Object {
+	if casted, ok := branch.(*types.List); ok {
+		if header, _ := casted.LoadInt(0).(types.Identifier); header != names.Block {
+			return evalStatements(types.MakeLocalEnvironment(env), types.NewList(casted).Iter())
+		}
+	}
+	return branch.Eval(env)
+}
+
+// the results of a function are described by None, a type or "(list results...)",
+// otherwise they are missing and the object is the first instruction of the body
+func isResultsDesc(o types.Object) bool {
+	switch casted := o.(type) {
+	case types.NoneType, types.Identifier:
+		return true
+	case *types.List:
+		switch header, _ := casted.LoadInt(0).(types.Identifier); header {
+		case names.ArrowChanId, names.ChanArrowId, names.ChanId, names.FuncId, names.GenId, names.GetId,
+			names.InterfaceId, names.ListId, names.MapId, names.SliceId, names.StarId, names.StructId:
+			return true
+		}
+	}
+	return false
+}
+
+// the customType is created when unknown
+func loadCustomType(env types.Environment, typeName string) customType {
+	customTypes, _ := env.LoadStr(hiddenTypesName)
+	castedTypes, _ := customTypes.(types.BaseEnvironment)
+	objectType, ok := castedTypes.LoadStr(typeName)
+	if castedType, _ := objectType.(customType); ok {
+		return castedType
+	}
+
+	castedType := customType{methods: map[string]types.Appliable{}, underlying: types.None}
+	castedTypes.StoreStr(typeName, castedType)
+	return castedType
+}
+
+// handle "(name:type...)" parameters (the type of the last one could be "(... type)"),
+// the receiver (when not empty) is the first parameter
+func makeUserFunc(env types.Environment, receiver string, paramDesc types.Object, resultDesc types.Object, body iter.Seq[types.Object]) userFunc {
+	f := userFunc{env: env}
+	if receiver != "" {
+		f.params = append(f.params, receiver)
+	}
+	if params, ok := paramDesc.(*types.List); ok {
+		for param := range params.Iter() {
+			if casted, ok := param.(*types.List); ok {
+				param = casted.LoadInt(1)
+				if typeDesc, ok := casted.LoadInt(2).(*types.List); ok {
+					header, _ := typeDesc.LoadInt(0).(types.Identifier)
+					f.variadic = header == names.EllipsisId
+				}
+			}
+
+			id, ok := param.(types.Identifier)
+			if !ok {
+				panic(errIdentifierType)
+			}
+			f.params = append(f.params, string(id))
+		}
+	}
+
+	if !isResultsDesc(resultDesc) {
+		f.body = append(f.body, resultDesc)
+	} else if results, ok := resultDesc.(*types.List); ok {
+		if header, _ := results.LoadInt(0).(types.Identifier); header == names.ListId {
+			for index := 1; index < results.Size(); index++ {
+				if named, ok := results.LoadInt(index).(*types.List); ok {
+					f.named = append(f.named, named)
+				}
+			}
+		}
+	}
+	for form := range body {
+		f.body = append(f.body, form)
+	}
+	return f
+}
+
+func extractPackageName(path types.String) string {
+	casted := string(path)
+	splitIndex := strings.LastIndexByte(casted, '/') + 1 // 0 when not found, so splitting do nothing
+	return casted[splitIndex:]
+}
+
+// handle "(K name value)", "(K (list name type) value?)"
+// and several lines of "(name value)" or "((list name type) value?)"
+func processDef(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	args := types.NewList().AddAll(itArgs)
+	switch casted := args.LoadInt(0).(type) {
+	case types.Identifier:
+		processDefLine(env, args)
+	case *types.List:
+		if header, _ := casted.LoadInt(0).(types.Identifier); header == names.ListId {
+			processDefLine(env, args)
+			break
+		}
+
+		for line := range args.Iter() {
+			casted, ok := line.(*types.List)
+			if !ok {
+				panic(errListType)
+			}
+			processDefLine(env, casted)
+		}
+	default:
+		panic(errIdentifierType)
+	}
+	return types.None
+}
+
+// "(name value)" or "((list name type) value?)"
+func processDefLine(env types.Environment, line *types.List) {
+	var value types.Object
+	var name types.Object = line.LoadInt(0)
+	if casted, ok := name.(*types.List); ok {
+		name, value = casted.LoadInt(1), zeroValue(env, casted.LoadInt(2))
+	}
+	if line.Size() > 1 {
+		value = line.LoadInt(1).Eval(env)
+	}
+
+	id, ok := name.(types.Identifier)
+	if !ok {
+		panic(errIdentifierType)
+	}
+	if value == nil {
+		panic(errPairSize)
+	}
+	env.StoreStr(string(id), value)
+}
+
+// zero value of the type described by o (None for types without literal)
+func zeroValue(env types.Environment, o types.Object) types.Object {
+	switch casted := o.(type) {
+	case types.Identifier:
+		switch casted {
+		case "bool":
+			return types.Boolean(false)
+		case "byte", names.Int, names.Int8, names.Int16, names.Int32, names.Int64, "rune",
+			names.Uint, names.Uint8, names.Uint16, names.Uint32, names.Uint64, "uintptr":
+			return types.Integer(0)
+		case names.Float32, names.Float64:
+			return types.Float(0)
+		case "string":
+			return types.String("")
+		}
+
+		customTypes, _ := env.LoadStr(hiddenTypesName)
+		castedTypes, _ := customTypes.(types.BaseEnvironment)
+		objectType, _ := castedTypes.LoadStr(string(casted))
+		if castedType, ok := objectType.(customType); ok {
+			if underlying, ok := castedType.underlying.(*types.List); ok {
+				if header, _ := underlying.LoadInt(0).(types.Identifier); header == names.StructId {
+					return makeDynamicObject(env, string(casted))
+				}
+			}
+			return zeroValue(env, castedType.underlying)
+		}
+	case *types.List:
+		switch header, _ := casted.LoadInt(0).(types.Identifier); header {
+		case names.GenId:
+			return zeroValue(env, casted.LoadInt(1))
+		case names.StructId:
+			return makeAnonymousObject()
+		}
+	}
+	return types.None
+}
+
+// conversion to a type not tracked by eval mode (like interface)
+func identityConvForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	for arg := range itArgs {
+		return arg.Eval(env)
+	}
+	panic(errUnarySize)
+}
+
+// handle "(:= targets (range x))", "(= targets (range x))" and "(range x)",
+// the targets are an identifier or "(key value)"
+func extractRangeClause(header types.Object) (types.Object, func(types.Environment, types.Object) func(types.Object), *types.List, bool) {
+	casted, ok := header.(*types.List)
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	buildFunc := buildDeclareFunc
+	switch op, _ := casted.LoadInt(0).(types.Identifier); op {
+	case names.Range:
+		return types.NewList(), buildFunc, casted, true
+	case names.Assign:
+		buildFunc = buildAssignFunc
+	case names.DeclareAssign:
+	default:
+		return nil, nil, nil, false
+	}
+
+	rangeDesc, ok := casted.LoadInt(2).(*types.List)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	op, _ := rangeDesc.LoadInt(0).(types.Identifier)
+	return casted.LoadInt(1), buildFunc, rangeDesc, op == names.Range
+}
+
+// handle "(name type)" and "(type)", the type could be "(* type)" or generic
+func extractReceiver(receiverDesc *types.List) (string, string) {
+	receiver, typeDesc := "_", receiverDesc.LoadInt(0)
+	if receiverDesc.Size() > 1 {
+		receiverId, ok := typeDesc.(types.Identifier)
+		if !ok {
+			panic(errIdentifierType)
+		}
+		receiver, typeDesc = string(receiverId), receiverDesc.LoadInt(1)
+	}
+	if casted, ok := typeDesc.(*types.List); ok {
+		if op, _ := casted.LoadInt(0).(types.Identifier); op == names.StarId {
+			typeDesc = casted.LoadInt(1)
+		}
+	}
+	return receiver, extractTypeName(typeDesc)
+}
+
+// "(case value body...)" or "(default body...)"
+func extractClause(o types.Object) (*types.List, bool) {
+	if casted, ok := o.(*types.List); ok {
+		switch header, _ := casted.LoadInt(0).(types.Identifier); header {
+		case names.Case, names.Default:
+			return casted, true
+		}
+	}
+	return nil, false
+}
+
+// the value of a case is an expression or a list of expressions
+func matchCase(env types.Environment, values types.Object, tag types.Object) bool {
+	if casted, ok := values.(*types.List); ok {
+		if _, isExpr := casted.LoadInt(0).(types.Identifier); !isExpr {
+			for value := range casted.Iter() {
+				if equals(value.Eval(env), tag) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return equals(values.Eval(env), tag)
+}
+
+// blank and dot imports are not bound
+func storePackage(env types.Environment, name string, path types.String) {
+	if name != "_" && name != "." {
+		env.StoreStr(name, importedPackage{path: string(path)})
+	}
+}
+
+func storeCustomType(env types.Environment, typeName string, castedType customType) {
+	customTypes, _ := env.LoadStr(hiddenTypesName)
+	castedTypes, _ := customTypes.(types.BaseEnvironment)
+	castedTypes.StoreStr(typeName, castedType)
+}
+
+// struct types are initialized like literals, other types use the conversion of their underlying type
+func typeConversion(env types.Environment, typeName string, underlying types.Object) types.Object {
+	switch casted := underlying.(type) {
+	case types.Identifier:
+		if conversion, ok := env.LoadStr(string(casted)); ok {
+			if appliable, ok := conversion.(types.Appliable); ok {
+				return appliable
+			}
+		}
+	case *types.List:
+		switch header, _ := casted.LoadInt(0).(types.Identifier); header {
+		case names.MapId:
+			return initOrConvertMapAppliable
+		case names.StructId:
+			return literalForm(env, types.NewList(types.Identifier(typeName)).Iter())
+		}
+	}
+	return identityConvAppliable
+}
@@ -15,6 +15,7 @@ package eval
 
 import (
 	"iter"
+	"slices"
 
 	"github.com/dvaumoron/foresee/builtins/names"
 	"github.com/dvaumoron/foresee/types"
@@ -33,12 +34,7 @@ func assertForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Obje
 }
 
 func blockForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	// TODO manage break and continue ?
-	for o := range itArgs {
-		o.Eval((env))
-	}
-
-	return types.None
+	return evalStatements(types.MakeLocalEnvironment(env), itArgs)
 }
 
 func breakForm(_ types.Environment, itArgs iter.Seq[types.Object]) types.Object {
@@ -64,9 +60,7 @@ func closeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Objec
 }
 
 func constForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	// TODO
-
-	return types.None
+	return processDef(env, itArgs)
 }
 
 func continueForm(_ types.Environment, itArgs iter.Seq[types.Object]) types.Object {
@@ -104,23 +98,106 @@ func fileForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 	return types.None
 }
 
+// handle "(for cond body...)", "(for (init cond post) body...)", "(for nil body...)"
+// and "(for (:= (key value) (range x)) body...)" (or with "=" and without assignment)
 func forForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	// TODO
+	next, stop := types.Pull(itArgs)
+	defer stop()
 
-	return types.None
+	label := consumeLabel(env)
+	loopEnv := types.MakeLocalEnvironment(env)
+	header, _ := next()
+	body := slices.Collect(types.Push(next))
+
+	if targets, buildFunc, rangeDesc, ok := extractRangeClause(header); ok {
+		for key, value := range rangeOver(rangeDesc.LoadInt(1).Eval(loopEnv)) {
+			iterationEnv := types.MakeLocalEnvironment(loopEnv)
+			switch casted := targets.(type) {
+			case types.Identifier:
+				buildFunc(iterationEnv, casted)(key)
+			case *types.List:
+				buildFunc(iterationEnv, casted.LoadInt(0))(key)
+				if casted.Size() > 1 {
+					buildFunc(iterationEnv, casted.LoadInt(1))(value)
+				}
+			}
+
+			if stopLoop, res := handleLoopMarker(evalStatements(iterationEnv, slices.Values(body)), label); stopLoop {
+				return res
+			}
+		}
+		return types.None
+	}
+
+	var init, cond, post types.Object = types.None, header, types.None
+	if casted, ok := header.(*types.List); ok {
+		if _, ok := casted.LoadInt(0).(*types.List); ok {
+			init, cond, post = casted.LoadInt(0), casted.LoadInt(1), casted.LoadInt(2)
+		}
+	}
+
+	init.Eval(loopEnv)
+	for {
+		if _, infinite := cond.(types.NoneType); !infinite && !extractBoolean(cond.Eval(loopEnv)) {
+			return types.None
+		}
+
+		iterationEnv := types.MakeLocalEnvironment(loopEnv)
+		if stopLoop, res := handleLoopMarker(evalStatements(iterationEnv, slices.Values(body)), label); stopLoop {
+			return res
+		}
+		post.Eval(loopEnv)
+	}
 }
 
+// handle "(func name params results body...)" and "(func (receiver type) name params results body...)",
+// the name could be generic ("(gen name ...)") and the results could be missing
 func funcForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	// TODO
+	next, stop := types.Pull(itArgs)
+	defer stop()
 
+	arg0, _ := next()
+	receiver, typeName := "", ""
+	if casted, ok := arg0.(*types.List); ok {
+		if header, _ := casted.LoadInt(0).(types.Identifier); header != names.GenId {
+			receiver, typeName = extractReceiver(casted)
+			arg0, _ = next()
+		}
+	}
+
+	name := extractTypeName(arg0)
+	params, _ := next()
+	results, _ := next()
+	f := makeUserFunc(env, receiver, params, results, types.Push(next))
+	if typeName == "" {
+		env.StoreStr(name, f)
+	} else {
+		loadCustomType(env, typeName).methods[name] = f
+	}
 	return types.None
 }
 
+// return a new identifier (prefixed by the optional string argument),
+// distinct from every identifier of the file
+func gensymFunc(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	prefix := "g"
+	for arg := range itArgs {
+		casted, ok := arg.Eval(env).(types.String)
+		if !ok {
+			panic(errStringType)
+		}
+		prefix = string(casted)
+		break
+	}
+	return loadSymbols(env).generate(prefix)
+}
+
 func getForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
 	next, stop := types.Pull(itArgs)
 	defer stop()
 
-	res, _ := next()
+	arg0, _ := next()
+	res := arg0.Eval(env)
 	for elem := range types.Push(next) {
 		loadable, ok := res.(types.StringLoadable)
 		if !ok {
@@ -151,28 +228,78 @@ func gotoForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 	panic(errUnimplemented)
 }
 
+// handle "(if cond then else?)" and "(if (init... cond) then else?)",
+// each branch is a block or a single statement
 func ifForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	// TODO
+	next, stop := types.Pull(itArgs)
+	defer stop()
 
+	local := types.MakeLocalEnvironment(env)
+	arg0, _ := next()
+	arg1, _ := next()
+	if extractBoolean(evalHeader(local, arg0)) {
+		return evalBranch(local, arg1)
+	}
+	if arg2, ok := next(); ok {
+		return evalBranch(local, arg2)
+	}
 	return types.None
 }
 
+// bind the package names (eval mode can not use their members)
 func importForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	// TODO
+	next, stop := types.Pull(itArgs)
+	defer stop()
 
+	for importDesc := range types.Push(next) {
+		switch casted := importDesc.(type) {
+		case *types.List:
+			path, _ := casted.LoadInt(casted.Size() - 1).(types.String)
+			name := extractPackageName(path)
+			if casted.Size() > 1 {
+				nameId, _ := casted.LoadInt(0).(types.Identifier)
+				name = string(nameId)
+			}
+			storePackage(env, name, path)
+			continue
+		case types.Identifier:
+			path, _ := next()
+			castedPath, _ := path.(types.String)
+			storePackage(env, string(casted), castedPath)
+		case types.String:
+			storePackage(env, extractPackageName(casted), casted)
+		}
+		break // onliner cases so break
+	}
 	return types.None
 }
 
+// conversion to an anonymous interface (nothing to do, eval mode does not track interface type)
+func interfaceTypeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	return identityConvAppliable
+}
+
+// name the following loop or switch (target of labelled break and continue)
 func labelForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	// TODO use a label stack in env ?
+	for arg0 := range itArgs {
+		labelId, ok := arg0.(types.Identifier)
+		if !ok {
+			panic(errIdentifierType)
+		}
 
-	return types.None
+		env.StoreStr(hiddenLabelName, labelId)
+		return types.None
+	}
+	panic(errUnarySize)
 }
 
 func lambdaForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	// TODO
+	next, stop := types.Pull(itArgs)
+	defer stop()
 
-	return types.None
+	params, _ := next()
+	results, _ := next()
+	return makeUserFunc(env, "", params, results, types.Push(next))
 }
 
 func lenForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
@@ -188,6 +315,16 @@ func listFunc(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 func literalForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
 	typeName, notFind := "", true
 	for arg := range itArgs {
+		if casted, ok := arg.(*types.List); ok {
+			if header, _ := casted.LoadInt(0).(types.Identifier); header == names.StructId {
+				next, stop := types.Pull(casted.Iter())
+				defer stop()
+				next() // skip header
+
+				return structTypeForm(env, types.Push(next))
+			}
+		}
+
 		typeName, notFind = extractTypeName(arg), false
 		break
 	}
@@ -219,8 +356,17 @@ func literalForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Obj
 }
 
 func macroForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	// TODO
+	next, stop := types.Pull(itArgs)
+	defer stop()
 
+	arg0, _ := next()
+	name, ok := arg0.(types.Identifier)
+	if !ok {
+		panic(errIdentifierType)
+	}
+
+	params, _ := next()
+	env.StoreStr(string(name), makeUserMacro(env, params, types.Push(next)))
 	return types.None
 }
 
@@ -240,16 +386,87 @@ func newForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 	return types.None
 }
 
-func rangeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	// TODO
+// build the argument without evaluating it, except the unquoted parts
+// identifiers bound by the template are renamed (the same way for a whole macro expansion),
+// ",(quote name)" allows to bind a name visible to the caller
+func quasiquoteForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	for arg := range itArgs {
+		renamed := loadRenaming(env)
+		collectBinders(arg, func(name string) {
+			if _, ok := renamed.names[name]; !ok {
+				renamed.names[name] = loadSymbols(env).generate(name)
+			}
+		})
+		return quasiquoteObject(env, arg, renamed)
+	}
+	panic(errUnarySize)
+}
 
-	return types.None
+// return the argument without evaluating it (to build code in macro)
+func quoteForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	for arg := range itArgs {
+		return arg
+	}
+	panic(errUnarySize)
+}
+
+// outside of a for clause, return the list of the (key value) pairs
+func rangeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	for arg0 := range itArgs {
+		res := types.NewList()
+		for key, value := range rangeOver(arg0.Eval(env)) {
+			res.Add(types.NewList(key, value))
+		}
+		return res
+	}
+	panic(errUnarySize)
 }
 
 func returnForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	// TODO
+	values := types.NewList().AddAll(evalIterator(itArgs, env))
+	switch values.Size() {
+	case 0:
+		return returnMarker{}
+	case 1:
+		return returnMarker{value: values.LoadInt(0)}
+	}
+	return returnMarker{value: values}
+}
 
-	return types.None
+// (rule name (pattern...) body...) return a rule for the parser,
+// the identifiers of the pattern are bound to the matching nodes
+func ruleForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	next, stop := types.Pull(itArgs)
+	defer stop()
+
+	arg0, _ := next()
+	name, ok := arg0.(types.Identifier)
+	if !ok {
+		panic(errIdentifierType)
+	}
+
+	arg1, _ := next()
+	pattern, ok := arg1.(*types.List)
+	if !ok {
+		panic(errListType)
+	}
+
+	rule := userRule{env: env}
+	for elem := range pattern.Iter() {
+		switch casted := elem.(type) {
+		case types.Identifier:
+			rule.params = append(rule.params, string(casted))
+		case types.String:
+		default:
+			panic(errPatternType)
+		}
+	}
+	for form := range types.Push(next) {
+		rule.body = append(rule.body, form)
+	}
+
+	env.StoreStr(string(name), rule)
+	return rule
 }
 
 func selectForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
@@ -264,20 +481,171 @@ func sliceOrArrayTypeForm(env types.Environment, itArgs iter.Seq[types.Object])
 	return types.None
 }
 
+// the fields of an anonymous struct are given by name (keyed pairs) or in declaration order
+func structTypeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	var fieldNames []string
+	for field := range itArgs {
+		fieldNames = append(fieldNames, extractFieldName(field))
+	}
+
+	return types.MakeNativeAppliable(func(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+		args := types.NewList().AddAll(itArgs)
+		if casted, ok := args.LoadInt(0).(*types.List); ok {
+			if id, _ := casted.LoadInt(0).(types.Identifier); id == names.ListId {
+				return initFromPairs[types.Environment](env, args.Iter(), makeAnonymousObject(), 3, structPairAdder)
+			}
+		}
+
+		if args.Size() > len(fieldNames) {
+			panic(errFieldCount)
+		}
+
+		res := makeAnonymousObject()
+		for index, fieldName := range fieldNames[:args.Size()] {
+			res.StoreStr(fieldName, args.LoadInt(index).Eval(env))
+		}
+		return res
+	})
+}
+
+// handle "(switch tag? clauses...)" and "(switch (init... tag) clauses...)",
+// a clause is "(case value body...)", "(case (values...) body...)" or "(default body...)"
 func switchForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	// TODO
+	args := types.NewList().AddAll(itArgs)
+	label := consumeLabel(env)
+	local := types.MakeLocalEnvironment(env)
+
+	start := 0
+	var tag types.Object = types.Boolean(true)
+	if _, isClause := extractClause(args.LoadInt(0)); !isClause {
+		tag, start = evalHeader(local, args.LoadInt(0)), 1
+	}
 
+	matched, defaultIndex := -1, -1
+	for index := start; index < args.Size() && matched == -1; index++ {
+		clause, ok := extractClause(args.LoadInt(index))
+		switch {
+		case !ok:
+			panic(errClauseType)
+		case clause.LoadInt(0) == types.Identifier(names.Default):
+			defaultIndex = index
+		case matchCase(local, clause.LoadInt(1), tag):
+			matched = index
+		}
+	}
+	if matched == -1 {
+		matched = defaultIndex
+	}
+	if matched == -1 {
+		return types.None
+	}
+
+	for index := matched; index < args.Size(); index++ {
+		clause, _ := extractClause(args.LoadInt(index))
+		bodyStart := 2
+		if clause.LoadInt(0) == types.Identifier(names.Default) {
+			bodyStart = 1
+		}
+
+		body, _ := clause.Load(types.NewList(types.Integer(bodyStart))).(*types.List)
+		res := evalStatements(types.MakeLocalEnvironment(local), body.Iter())
+		if marker, ok := res.(loopMarker); ok {
+			if marker.kind == fallthroughKind {
+				continue
+			}
+			if marker.kind == breakKind && (marker.label == "" || marker.label == label) {
+				return types.None
+			}
+		}
+		return res
+	}
 	return types.None
 }
 
-func typeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	// TODO
+// (try targets? call context?) bind the values before the error,
+// an error (not None) is returned, prefixed by the context when there is one
+func tryForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	args := types.NewList().AddAll(itArgs)
+	if args.Size() == 0 {
+		panic(errUnarySize)
+	}
+
+	var targets types.Object = types.None
+	start := 0
+	if _, isContext := args.LoadInt(1).(types.String); args.Size() > 1 && !isContext {
+		targets, start = args.LoadInt(0), 1
+	}
+
+	value := args.LoadInt(start).Eval(env)
+	values, ok := value.(*types.List)
+	if !ok {
+		values = types.NewList(value)
+	}
+
+	errValue := values.LoadInt(values.Size() - 1)
+	if _, isNone := errValue.(types.NoneType); !isNone {
+		if context, ok := args.LoadInt(start + 1).(types.String); ok {
+			message, isString := errValue.(types.String)
+			if !isString {
+				message = types.String(extractRenderString(errValue))
+			}
+			errValue = context + ": " + message
+		}
+		return returnMarker{value: errValue}
+	}
 
+	switch casted := targets.(type) {
+	case types.Identifier:
+		buildDeclareFunc(env, casted)(values.LoadInt(0))
+	case *types.List:
+		index := 0
+		for elem := range casted.Iter() {
+			assignFunc := buildDeclareFunc(env, elem)
+			if assignFunc == nil {
+				panic(errAssignableType)
+			}
+
+			assignFunc(values.LoadInt(index))
+			index++
+		}
+	}
 	return types.None
 }
 
-func varForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	// TODO
+// handle "(type name underlying)" and "(type name = aliased)", the name could be generic,
+// the type is registered (for its methods) and its name is bound to the conversion
+func typeForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	next, stop := types.Pull(itArgs)
+	defer stop()
 
+	arg0, _ := next()
+	underlying, ok := next()
+	if !ok {
+		panic(errPairSize)
+	}
+
+	typeName := extractTypeName(arg0)
+	if id, _ := underlying.(types.Identifier); id == names.Assign {
+		underlying, _ = next()
+	}
+	switch id, _ := underlying.(types.Identifier); id {
+	case names.InterfaceId, names.StructId:
+		// "(type name struct fields...)"
+		underlying = types.NewList(id).AddAll(types.Push(next))
+	}
+
+	castedType := loadCustomType(env, typeName)
+	castedType.underlying = underlying
+	storeCustomType(env, typeName, castedType)
+	env.StoreStr(typeName, typeConversion(env, typeName, underlying))
 	return types.None
 }
+
+// unquote and unquote-splicing are only meaningful inside a quasiquote
+func unquoteForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	panic(errUnquote)
+}
+
+func varForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	return processDef(env, itArgs)
+}
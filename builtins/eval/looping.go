@@ -14,11 +14,14 @@
 package eval
 
 import (
+	"errors"
 	"iter"
 
 	"github.com/dvaumoron/foresee/types"
 )
 
+var errRangeableType = errors.New("wait rangeable value")
+
 const (
 	breakKind       loopMarkerKind = iota
 	continueKind    loopMarkerKind = iota
@@ -33,6 +36,13 @@ type loopMarker struct {
 	label string
 }
 
+// result of a return form, stop the evaluation of the body
+// (value is nil for a return without value, like the naked one)
+type returnMarker struct {
+	types.NoneType
+	value types.Object
+}
+
 func processLabellable(itArgs iter.Seq[types.Object], kind loopMarkerKind) types.Object {
 	ok1 := false
 	var arg0 types.Object = types.None
@@ -48,3 +58,78 @@ func processLabellable(itArgs iter.Seq[types.Object], kind loopMarkerKind) types
 
 	return loopMarker{kind: kind, label: string(labelId)}
 }
+
+// evaluate the statements until one of them gives a marker (return, break, continue or fallthrough)
+func evalStatements(env types.Environment, statements iter.Seq[types.Object]) types.Object {
+	for statement := range statements {
+		switch res := statement.Eval(env).(type) {
+		case loopMarker, returnMarker:
+			return res
+		}
+	}
+	return types.None
+}
+
+// the label form preceding a loop or a switch name it
+func consumeLabel(env types.Environment) string {
+	label, _ := env.LoadStr(hiddenLabelName)
+	env.StoreStr(hiddenLabelName, types.None)
+	castedLabel, _ := label.(types.Identifier)
+	return string(castedLabel)
+}
+
+// return true when the marker from an iteration stops the loop,
+// res is the marker to propagate (None when handled by this loop)
+func handleLoopMarker(marker types.Object, label string) (bool, types.Object) {
+	switch casted := marker.(type) {
+	case loopMarker:
+		if casted.label != "" && casted.label != label {
+			return true, casted
+		}
+
+		switch casted.kind {
+		case breakKind:
+			return true, types.None
+		case continueKind:
+			return false, types.None
+		}
+		return true, casted
+	case returnMarker:
+		return true, casted
+	}
+	return false, types.None
+}
+
+// key and value pairs of a range clause
+func rangeOver(o types.Object) iter.Seq2[types.Object, types.Object] {
+	return func(yield func(types.Object, types.Object) bool) {
+		switch casted := o.(type) {
+		case types.Integer:
+			for index := range casted {
+				if !yield(index, types.None) {
+					return
+				}
+			}
+		case types.String:
+			for index, r := range string(casted) {
+				if !yield(types.Integer(index), types.Rune(r)) {
+					return
+				}
+			}
+		case *types.List:
+			for index := range casted.Size() {
+				if !yield(types.Integer(index), casted.LoadInt(index)) {
+					return
+				}
+			}
+		case dynamicMap:
+			for key, value := range casted.objects {
+				if !yield(types.String(key), value) {
+					return
+				}
+			}
+		default:
+			panic(errRangeableType)
+		}
+	}
+}
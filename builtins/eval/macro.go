@@ -13,9 +13,341 @@
 
 package eval
 
-import "github.com/dvaumoron/foresee/types"
+import (
+	"errors"
+	"iter"
+	"slices"
+	"strconv"
 
-func ExpandMacro(l *types.List) (*types.List, error) {
-	// TODO
-	return l, nil
+	"github.com/dvaumoron/foresee/builtins/names"
+	"github.com/dvaumoron/foresee/diagnostic"
+	"github.com/dvaumoron/foresee/types"
+)
+
+// expansions of a single call (protect against macro expanding to themselves)
+const maxExpansionSteps = 1000
+
+var (
+	errMacroArity = errors.New("wait a number of arguments matching the macro parameters")
+	errMacroLoop  = errors.New("wait a macro expansion ending before 1000 steps")
+)
+
+type userMacro struct {
+	types.NoneType
+	params   []string
+	variadic bool // last parameter receive the remaining arguments as a list
+	body     []types.Object
+	env      types.Environment // where the macro is defined
+}
+
+// call the macro with unevaluated arguments, the result is the expanded code
+func (m userMacro) expand(itArgs iter.Seq[types.Object]) types.Object {
+	args := types.NewList().AddAll(itArgs)
+
+	fixedSize := len(m.params)
+	if m.variadic {
+		fixedSize--
+	}
+	if size := args.Size(); size < fixedSize || (!m.variadic && size > fixedSize) {
+		panic(errMacroArity)
+	}
+
+	local := types.MakeLocalEnvironment(m.env)
+	local.StoreStr(hiddenRenamingName, renaming{names: map[string]types.Identifier{}})
+	for index, param := range m.params[:fixedSize] {
+		local.StoreStr(param, args.LoadInt(index))
+	}
+	if m.variadic {
+		local.StoreStr(m.params[fixedSize], args.Load(types.NewList(types.Integer(fixedSize))))
+	}
+	return evalBody(local, m.body)
+}
+
+// a macro called while evaluating (in another macro body) evaluate its expansion
+func (m userMacro) Apply(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	return m.expand(itArgs).Eval(env)
+}
+
+func makeUserMacro(env types.Environment, paramDesc types.Object, body iter.Seq[types.Object]) userMacro {
+	m := userMacro{env: env}
+	if params, ok := paramDesc.(*types.List); ok {
+		last := params.Size() - 1
+		for index := range params.Size() {
+			param := params.LoadInt(index)
+			if casted, ok := param.(*types.List); ok && index == last {
+				if header, _ := casted.LoadInt(0).(types.Identifier); header == names.EllipsisId {
+					param, m.variadic = casted.LoadInt(1), true
+				}
+			}
+
+			id, ok := param.(types.Identifier)
+			if !ok {
+				panic(errIdentifierType)
+			}
+			m.params = append(m.params, string(id))
+		}
+	}
+	for form := range body {
+		m.body = append(m.body, form)
+	}
+	return m
+}
+
+// reader extension (see ruleForm)
+type userRule struct {
+	types.NoneType
+	params []string
+	body   []types.Object
+	env    types.Environment
+}
+
+// receive the parsed nodes matching the parameters and return the node to insert
+func (r userRule) Apply(_ types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	local := types.MakeLocalEnvironment(r.env)
+	local.StoreStr(hiddenRenamingName, renaming{names: map[string]types.Identifier{}})
+
+	index := 0
+	for arg := range itArgs {
+		if index == len(r.params) {
+			panic(errMacroArity)
+		}
+		local.StoreStr(r.params[index], arg)
+		index++
+	}
+	if index != len(r.params) {
+		panic(errMacroArity)
+	}
+	return evalBody(local, r.body)
+}
+
+// evaluate forms until a return
+func evalBody(env types.Environment, body []types.Object) types.Object {
+	if marker, ok := evalStatements(env, slices.Values(body)).(returnMarker); ok && marker.value != nil {
+		return marker.value
+	}
+	return types.None
+}
+
+// Collect the macro definitions at the top level of the file (removing them),
+// then replace every macro call by its expansion until no call remains.
+func ExpandMacro(l *types.List) (res *types.List, err error) {
+	symbols := &symbolGenerator{used: map[string]struct{}{}}
+	symbols.collectUsed(l)
+
+	env := types.MakeLocalEnvironment(Builtins)
+	env.StoreStr(hiddenTypesName, types.MakeBaseEnvironment())
+	env.StoreStr(hiddenSymbolsName, symbols)
+
+	var current types.Span
+	defer func() {
+		if r := recover(); r != nil {
+			casted, ok := r.(error)
+			if !ok {
+				panic(r)
+			}
+			res, err = nil, diagnostic.List{{Span: current, Severity: diagnostic.Error, Message: casted.Error()}}
+		}
+	}()
+
+	res = types.NewList().SetSpan(l.Span())
+	for index := range l.Size() {
+		form := l.LoadInt(index)
+		if casted, ok := form.(*types.List); ok {
+			if header, _ := casted.LoadInt(0).(types.Identifier); header == names.Macro {
+				current = casted.Span()
+				casted.Eval(env)
+				continue
+			}
+		}
+		res.AddWithSpan(form, l.SpanInt(index))
+	}
+
+	expandChildren(env, res, &current)
+	return res, nil
+}
+
+// replace the macro calls inside l (current follow the expanded call to locate errors)
+func expandChildren(env types.Environment, l *types.List, current *types.Span) {
+	for index := range l.Size() {
+		if casted, ok := l.LoadInt(index).(*types.List); ok {
+			expanded := expandForm(env, casted, current)
+			l.Store(types.Integer(index), expanded)
+			if expandedList, ok := expanded.(*types.List); ok {
+				expandChildren(env, expandedList, current)
+			}
+		}
+	}
+}
+
+// expand form while it is a macro call
+func expandForm(env types.Environment, form *types.List, current *types.Span) types.Object {
+	var res types.Object = form
+	for range maxExpansionSteps {
+		casted, ok := res.(*types.List)
+		if !ok {
+			return res
+		}
+
+		id, _ := casted.LoadInt(0).(types.Identifier)
+		value, _ := env.LoadStr(string(id))
+		m, ok := value.(userMacro)
+		if !ok {
+			return res
+		}
+
+		*current = casted.Span()
+		args := casted.Load(types.NewList(types.Integer(1))).(*types.List)
+		res = relocate(m.expand(args.Iter()), *current)
+	}
+	panic(errMacroLoop)
+}
+
+// copy the generated code (an argument used twice must not share its lists),
+// lists without position receive the span of the call
+func relocate(o types.Object, span types.Span) types.Object {
+	casted, ok := o.(*types.List)
+	if !ok {
+		return o
+	}
+
+	if listSpan := casted.Span(); listSpan.IsValid() {
+		span = listSpan
+	}
+	res := types.NewList().SetSpan(span)
+	for index := range casted.Size() {
+		res.AddWithSpan(relocate(casted.LoadInt(index), span), casted.SpanInt(index))
+	}
+	return res
+}
+
+// shared by the macro expansions of a file
+type symbolGenerator struct {
+	types.NoneType
+	used  map[string]struct{}
+	count int
+}
+
+func (g *symbolGenerator) generate(prefix string) types.Identifier {
+	for {
+		g.count++
+		name := prefix + "_" + strconv.Itoa(g.count)
+		if _, ok := g.used[name]; !ok {
+			g.used[name] = struct{}{}
+			return types.Identifier(name)
+		}
+	}
+}
+
+func loadSymbols(env types.Environment) *symbolGenerator {
+	if value, ok := env.LoadStr(hiddenSymbolsName); ok {
+		if casted, ok := value.(*symbolGenerator); ok {
+			return casted
+		}
+	}
+
+	// outside of ExpandMacro
+	g := &symbolGenerator{used: map[string]struct{}{}}
+	env.StoreStr(hiddenSymbolsName, g)
+	return g
+}
+
+// record the identifiers used in o (generated ones must differ)
+func (g *symbolGenerator) collectUsed(o types.Object) {
+	switch casted := o.(type) {
+	case types.Identifier:
+		g.used[string(casted)] = struct{}{}
+	case *types.List:
+		for elem := range casted.Iter() {
+			g.collectUsed(elem)
+		}
+	}
+}
+
+type renaming struct {
+	types.NoneType
+	names map[string]types.Identifier
+}
+
+func loadRenaming(env types.Environment) renaming {
+	if value, ok := env.LoadStr(hiddenRenamingName); ok {
+		if casted, ok := value.(renaming); ok {
+			return casted
+		}
+	}
+
+	// quasiquote outside of a macro
+	r := renaming{names: map[string]types.Identifier{}}
+	env.StoreStr(hiddenRenamingName, r)
+	return r
+}
+
+// call yield with the identifiers declared by the template o
+// (with ":=", var, const, try, function parameters or in a for clause)
+func collectBinders(o types.Object, yield func(string)) {
+	list, ok := o.(*types.List)
+	if !ok {
+		return
+	}
+
+	switch header, _ := list.LoadInt(0).(types.Identifier); header {
+	case names.UnquoteId, names.UnquoteSplicingId:
+		return // code from the caller
+	case names.DeclareAssign, names.Var, names.Const:
+		collectDeclared(list.LoadInt(1), yield)
+	case names.Try:
+		if _, isContext := list.LoadInt(2).(types.String); list.Size() > 2 && !isContext {
+			collectDeclared(list.LoadInt(1), yield) // the form has targets
+		}
+	case names.FuncId:
+		paramsIndex := 2
+		if receiver, ok := list.LoadInt(1).(*types.List); ok {
+			if header, _ := receiver.LoadInt(0).(types.Identifier); header != names.GenId {
+				collectDeclared(receiver.LoadInt(0), yield)
+				paramsIndex = 3
+			}
+		}
+		collectParams(list.LoadInt(paramsIndex), yield)
+	case names.Lambda:
+		collectParams(list.LoadInt(1), yield)
+	case names.For:
+		for index := range list.Size() {
+			if id, _ := list.LoadInt(index).(types.Identifier); id == names.DeclareAssign {
+				collectDeclared(list.LoadInt(index+1), yield)
+			}
+		}
+	}
+
+	for elem := range list.Iter() {
+		collectBinders(elem, yield)
+	}
+}
+
+func collectParams(o types.Object, yield func(string)) {
+	if params, ok := o.(*types.List); ok {
+		for param := range params.Iter() {
+			collectDeclared(param, yield)
+		}
+	}
+}
+
+// handle "a", "(list a type)", "(... a)" and "(a b)"
+func collectDeclared(o types.Object, yield func(string)) {
+	switch casted := o.(type) {
+	case types.Identifier:
+		if casted != names.GuessMarker {
+			yield(string(casted))
+		}
+	case *types.List:
+		switch header, _ := casted.LoadInt(0).(types.Identifier); header {
+		case names.ListId, names.EllipsisId:
+			collectDeclared(casted.LoadInt(1), yield)
+		case names.UnquoteId, names.UnquoteSplicingId:
+		default:
+			for elem := range casted.Iter() {
+				if id, ok := elem.(types.Identifier); ok {
+					collectDeclared(id, yield)
+				}
+			}
+		}
+	}
 }
@@ -0,0 +1,141 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package eval_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dvaumoron/foresee/builtins/debug"
+	"github.com/dvaumoron/foresee/builtins/eval"
+	"github.com/dvaumoron/foresee/parser"
+)
+
+// parse and expand source, then return the display of the last top level node
+func expandLast(t *testing.T, source string) (string, error) {
+	t.Helper()
+
+	l, err := parser.New().Parse("test.fc", strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, err = eval.ExpandMacro(l); err != nil {
+		return "", err
+	}
+
+	var buffer strings.Builder
+	l.LoadInt(l.Size() - 1).Eval(debug.DebugEnvironment{}).Render(&buffer)
+	return buffer.String(), nil
+}
+
+func checkExpansion(t *testing.T, source string, expected string) {
+	t.Helper()
+
+	res, err := expandLast(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != expected {
+		t.Errorf("got %s, want %s", res, expected)
+	}
+}
+
+func TestBuiltinNotAssignable(t *testing.T) {
+	_, err := expandLast(t, `package a
+
+macro Break ()
+    = + 42
+    return 1
+
+var a (Break)
+`)
+	if err == nil || !strings.Contains(err.Error(), "builtin") {
+		t.Errorf("wait a builtin assignment error, got %v", err)
+	}
+
+	// the builtins of another file are untouched
+	checkExpansion(t, `package b
+
+macro Three ()
+    return (+ 1 2)
+
+var b (Three)
+`, "(var b 3)")
+}
+
+func TestAssignOuterScope(t *testing.T) {
+	checkExpansion(t, `package p
+
+macro Count ()
+    := n 0
+    for (< n 3)
+        = n (+ n 1)
+    return n
+
+var a (Count)
+`, "(var a 3)")
+}
+
+// the expansion of a template gives the same code as the one written directly
+func TestQuasiquoteRoundTrip(t *testing.T) {
+	expanded, err := expandLast(t, `package p
+
+macro Call (f ...args)
+    return `+"`"+`(,f 0 ,@args)
+
+var a (Call g 1 (+ 2 3))
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := expandLast(t, `package p
+
+var a (g 0 1 (+ 2 3))
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expanded != written {
+		t.Errorf("got %s, want %s", expanded, written)
+	}
+}
+
+// the names bound by a template do not capture the ones of the caller
+func TestHygiene(t *testing.T) {
+	checkExpansion(t, `package p
+
+macro Swap (a b)
+    return `+"`"+`(block
+        := tmp ,a
+        = ,a ,b
+        = ,b tmp)
+
+func F (tmp:int tmp_1:int)
+    Swap tmp tmp_1
+`, "(func F ((list tmp int) (list tmp_1 int)) (block (:= tmp_2 tmp) (= tmp tmp_1) (= tmp_1 tmp_2)))")
+
+	// a quoted name stays visible to the caller, gensym gives fresh names
+	checkExpansion(t, `package p
+
+macro Define (value)
+    := name (gensym "v")
+    return `+"`"+`(block
+        := ,name ,value
+        := ,(quote it) ,name)
+
+func F ()
+    Define 1
+`, "(func F nil (block (:= v_1 1) (:= it v_1)))")
+}
@@ -53,6 +53,42 @@ func inplaceUnaryOperatorForm(env types.Environment, itArgs iter.Seq[types.Objec
 	return types.NewList(types.Identifier(names.Assign), arg0, opCall).Eval(env)
 }
 
+// the values of a call with multiple results are spread over the targets
+func processAssign(env types.Environment, itArgs iter.Seq[types.Object], buildFunc func(types.Environment, types.Object) func(types.Object)) types.Object {
+	next, stop := types.Pull(itArgs)
+	defer stop()
+
+	arg0, _ := next()
+	values := types.NewList().AddAll(evalIterator(types.Push(next), env))
+	switch casted := arg0.(type) {
+	case types.Identifier:
+		buildFunc(env, casted)(values.LoadInt(0))
+	case *types.List:
+		if assignFunc := buildAssignFuncFromList(env, casted); assignFunc != nil {
+			assignFunc(values.LoadInt(0))
+
+			break
+		}
+
+		if multiple, ok := values.LoadInt(0).(*types.List); ok && values.Size() == 1 && casted.Size() > 1 {
+			values = multiple
+		}
+
+		index := 0
+		for elem := range casted.Iter() {
+			assignFunc := buildFunc(env, elem)
+			if assignFunc == nil {
+				panic(errAssignableType)
+			}
+
+			assignFunc(values.LoadInt(index))
+			index++
+		}
+	}
+
+	return types.None
+}
+
 func processUnaryOrBinaryMoreFunc(env types.Environment, itArgs iter.Seq[types.Object], unaryFunc types.NativeFunc, binaryMoreFunc types.NativeFunc) types.Object {
 	args := types.NewList().AddAll(itArgs)
 
@@ -30,35 +30,7 @@ func andForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object
 }
 
 func assignForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
-	next, stop := types.Pull(itArgs)
-	defer stop()
-
-	arg0, _ := next()
-	values := types.NewList().AddAll(evalIterator(types.Push(next), env))
-	switch casted := arg0.(type) {
-	case types.Identifier:
-		env.StoreStr(string(casted), values.LoadInt(0))
-	case *types.List:
-		if assignFunc := buildAssignFuncFromList(env, casted); assignFunc != nil {
-			assignFunc(values.LoadInt(0))
-
-			break
-		}
-
-		index := 0
-		for elem := range casted.Iter() {
-			assignFunc := buildAssignFunc(env, elem)
-			if assignFunc == nil {
-				panic(errAssignableType)
-			}
-
-			assignFunc(values.LoadInt(index))
-			index++
-
-		}
-	}
-
-	return types.None
+	return processAssign(env, itArgs, buildAssignFunc)
 }
 
 func bitwiseAndAssignForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
@@ -122,6 +94,10 @@ func callMethodForm(env types.Environment, itArgs iter.Seq[types.Object]) types.
 	return method.Apply(env, augmentedItArgs)
 }
 
+func declareAssignForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	return processAssign(env, itArgs, buildDeclareFunc)
+}
+
 func decrementForm(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
 	return inplaceUnaryOperatorForm(env, itArgs, names.Minus)
 }
@@ -166,6 +142,7 @@ func indexOrSliceForm(env types.Environment, itArgs iter.Seq[types.Object]) type
 	defer stop()
 
 	res, _ := next()
+	res = res.Eval(env)
 	for elem := range types.Push(next) {
 		loadable, ok := res.(types.Loadable)
 		if !ok {
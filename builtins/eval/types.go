@@ -15,14 +15,18 @@ package eval
 
 import (
 	"errors"
+	"fmt"
 	"iter"
+	"slices"
 
 	"github.com/dvaumoron/foresee/types"
 )
 
 var (
+	errArgumentCount  = errors.New("wait a number of arguments matching the function parameters")
 	errBooleanType    = errors.New("wait boolean value")
 	errIdentifierType = errors.New("wait identifier type")
+	errImportedMember = errors.New("imported package member unusable in eval mode")
 	errIntegerType    = errors.New("wait integer value")
 	errListType       = errors.New("wait list type")
 	errNumericType    = errors.New("wait numeric value")
@@ -77,6 +81,16 @@ func (d dynamicObject) Load(key types.Object) types.Object {
 	return types.Load(d, key)
 }
 
+// already a value (the receiver is an argument of its methods)
+func (d dynamicObject) Eval(env types.Environment) types.Object {
+	return d
+}
+
+// value of an anonymous struct (no methods)
+func makeAnonymousObject() dynamicObject {
+	return dynamicObject{BaseEnvironment: types.MakeBaseEnvironment()}
+}
+
 func makeDynamicObject(env types.Environment, typeName string) dynamicObject {
 	customTypes, _ := env.LoadStr(hiddenTypesName)
 	castedTypes, _ := customTypes.(types.BaseEnvironment)
@@ -88,7 +102,8 @@ func makeDynamicObject(env types.Environment, typeName string) dynamicObject {
 
 type customType struct {
 	types.NoneType
-	methods map[string]types.Appliable
+	methods    map[string]types.Appliable
+	underlying types.Object // type description, "(struct)" for struct types
 }
 
 func (c customType) LoadStr(key string) (types.Object, bool) {
@@ -98,3 +113,66 @@ func (c customType) LoadStr(key string) (types.Object, bool) {
 
 	return types.None, false
 }
+
+// function value (declared with func or lambda) closing over the environment of its definition
+type userFunc struct {
+	types.NoneType
+	params   []string
+	variadic bool          // last parameter receive the remaining arguments as a list
+	named    []*types.List // "(list name type)" of each named result
+	body     []types.Object
+	env      types.Environment
+}
+
+// the arguments are evaluated in the caller environment,
+// several results are returned as a list
+func (f userFunc) Apply(env types.Environment, itArgs iter.Seq[types.Object]) types.Object {
+	args := types.NewList().AddAll(evalIterator(itArgs, env))
+
+	fixedSize := len(f.params)
+	if f.variadic {
+		fixedSize--
+	}
+	if size := args.Size(); size < fixedSize || (!f.variadic && size > fixedSize) {
+		panic(errArgumentCount)
+	}
+
+	local := types.MakeLocalEnvironment(f.env)
+	for index, param := range f.params[:fixedSize] {
+		local.StoreStr(param, args.LoadInt(index))
+	}
+	if f.variadic {
+		local.StoreStr(f.params[fixedSize], args.Load(types.NewList(types.Integer(fixedSize))))
+	}
+	for _, named := range f.named {
+		nameId, _ := named.LoadInt(1).(types.Identifier)
+		local.StoreStr(string(nameId), zeroValue(local, named.LoadInt(2)))
+	}
+
+	if marker, ok := evalStatements(local, slices.Values(f.body)).(returnMarker); ok && marker.value != nil {
+		return marker.value
+	}
+
+	// no return or a naked one
+	values := types.NewList()
+	for _, named := range f.named {
+		values.Add(named.LoadInt(1).Eval(local))
+	}
+	if values.Size() == 1 {
+		return values.LoadInt(0)
+	}
+	if values.Size() == 0 {
+		return types.None
+	}
+	return values
+}
+
+// imported Go package, eval mode can not use its members
+type importedPackage struct {
+	types.NoneType
+	path string
+}
+
+func (p importedPackage) LoadStr(key string) (types.Object, bool) {
+	panic(fmt.Errorf("%w : %s.%s", errImportedMember, p.path, key))
+}
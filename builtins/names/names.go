@@ -48,10 +48,11 @@ const (
 	Float32       = "float32"
 	Float64       = "float64"
 	For           = "for"
+	Gensym        = "gensym"
 	Go            = "go"
 	Goto          = "goto"
-	Greater       = "<"
-	GreaterEqual  = "<="
+	Greater       = ">"
+	GreaterEqual  = ">="
 	GuessMarker   = "?"
 	If            = "if"
 	Import        = "import"
@@ -61,12 +62,11 @@ const (
 	Int16         = "int16"
 	Int32         = "int32"
 	Int64         = "int64"
-	Interface     = "interface"
 	Label         = "label"
 	Lambda        = "lambda"
 	Len           = "len"
-	Lesser        = ">"
-	LesserEqual   = ">="
+	Lesser        = "<"
+	LesserEqual   = "<="
 	LShift        = "<<"
 	LShiftAssign  = "<<="
 	Macro         = "macro"
@@ -82,15 +82,17 @@ const (
 	Percent       = "%"
 	Pipe          = "|"
 	Plus          = "+"
+	Quote         = "quote"
 	Range         = "range"
 	Return        = "return"
+	Rule          = "rule"
 	RShift        = ">>"
 	RShiftAssign  = ">>="
 	Select        = "select"
 	Slash         = "/"
-	Struct        = "struct"
 	SubAssign     = "-="
 	Switch        = "switch"
+	Try           = "try"
 	Type          = "type"
 	Uint          = "uint"
 	Uint8         = "uint8"
@@ -100,23 +102,28 @@ const (
 	Var           = "var"
 	XorAssign     = "^="
 
-	AmpersandId types.Identifier = "&"
-	ArrowChanId types.Identifier = "<-chan"
-	ChanArrowId types.Identifier = "chan<-"
-	ChanId      types.Identifier = "chan"
-	EllipsisId  types.Identifier = "..."
-	FileId      types.Identifier = "file"
-	FuncId      types.Identifier = "func"
-	GenId       types.Identifier = "gen"
-	GetId       types.Identifier = "get"
-	ListId      types.Identifier = "list"
-	LitId       types.Identifier = "lit"
-	LoadId      types.Identifier = "[]"
-	MapId       types.Identifier = "map"
-	NotId       types.Identifier = "!"
-	SliceId     types.Identifier = "slice"
-	StarId      types.Identifier = "*"
-	StoreId     types.Identifier = "[]="
-	TildeId     types.Identifier = "~"
-	UnquoteId   types.Identifier = "unquote"
+	AmpersandId       types.Identifier = "&"
+	ArrowChanId       types.Identifier = "<-chan"
+	ChanArrowId       types.Identifier = "chan<-"
+	ChanId            types.Identifier = "chan"
+	EllipsisId        types.Identifier = "..."
+	FileId            types.Identifier = "file"
+	FuncId            types.Identifier = "func"
+	GenId             types.Identifier = "gen"
+	GetId             types.Identifier = "get"
+	InstId            types.Identifier = "inst"
+	InterfaceId       types.Identifier = "interface"
+	ListId            types.Identifier = "list"
+	LitId             types.Identifier = "lit"
+	LoadId            types.Identifier = "[]"
+	MapId             types.Identifier = "map"
+	NotId             types.Identifier = "!"
+	QuasiquoteId      types.Identifier = "quasiquote"
+	SliceId           types.Identifier = "slice"
+	StarId            types.Identifier = "*"
+	StoreId           types.Identifier = "[]="
+	StructId          types.Identifier = "struct"
+	TildeId           types.Identifier = "~"
+	UnquoteId         types.Identifier = "unquote"
+	UnquoteSplicingId types.Identifier = "unquote-splicing"
 )
@@ -0,0 +1,145 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+)
+
+const (
+	cacheDir  = ".foresee"
+	cacheFile = "cache.json"
+)
+
+// keys of the files generated by a previous run
+type buildCache struct {
+	Version string            `json:"version"`
+	Files   map[string]string `json:"files"` // key of the package by foresee file path
+}
+
+// an unreadable cache is considered empty
+func loadCache(force bool) *buildCache {
+	cache := &buildCache{Files: map[string]string{}}
+	if force {
+		return cache
+	}
+
+	data, err := os.ReadFile(filepath.Join(cacheDir, cacheFile))
+	if err != nil {
+		return cache
+	}
+
+	var stored buildCache
+	if json.Unmarshal(data, &stored) != nil || stored.Version != foreseeVersion() || stored.Files == nil {
+		return cache
+	}
+	return &stored
+}
+
+func (c *buildCache) save() error {
+	c.Version = foreseeVersion()
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(cacheDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(cacheDir, cacheFile), data, 0644)
+}
+
+// a package is up to date when its files were generated with the same key and the outputs still exist
+func (c *buildCache) upToDate(pkg *packageFiles, opts *options) bool {
+	for _, filePath := range pkg.outputs() {
+		if c.Files[filePath] != pkg.key {
+			return false
+		}
+		if _, err := os.Stat(opts.outputPath(filePath)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func foreseeVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	version := info.Main.Version
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" || setting.Key == "vcs.modified" {
+			version += " " + setting.Value
+		}
+	}
+	return version
+}
+
+// the key of a package change when its sources change (with the macros and rules they define),
+// when the key of a foresee dependency change (so dependents are regenerated)
+// or when the signatures it could import change (go.mod, go.sum and Go packages of the module)
+func computeKey(pkg *packageFiles) string {
+	hash := sha256.New()
+	writeField := func(s string) {
+		hash.Write([]byte(s))
+		hash.Write([]byte{0})
+	}
+
+	writeField(foreseeVersion())
+	for index, filePath := range pkg.filePaths {
+		writeField(filePath)
+		writeField(pkg.sums[index])
+	}
+	for _, name := range []string{"go.mod", "go.sum"} {
+		writeField(hashFile(name))
+	}
+	for _, dep := range pkg.deps {
+		writeField(dep.path)
+		writeField(dep.key)
+	}
+	for _, dir := range pkg.goDirs {
+		goFiles, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+		slices.Sort(goFiles)
+		for _, goFile := range goFiles {
+			if !strings.HasSuffix(goFile, "_test.go") {
+				writeField(goFile)
+				writeField(hashFile(goFile))
+			}
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func hashData(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// empty when the file can not be read
+func hashFile(filePath string) string {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return ""
+	}
+	return hashData(data)
+}
@@ -0,0 +1,90 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// run the test in a temporary directory (the paths of the cache are relative)
+func chdirTemp(t *testing.T) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+	})
+}
+
+func writeFile(t *testing.T, filePath string, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// package b imports package a, return them marked
+func markPackages(cache *buildCache, sumA string) (*packageFiles, *packageFiles) {
+	a := &packageFiles{dir: "a", path: "example.com/m/a", filePaths: []string{"a/a.fc"}, sums: []string{sumA}}
+	b := &packageFiles{dir: "b", path: "example.com/m/b", filePaths: []string{"b/b.fc"}, sums: []string{"b"}, deps: []*packageFiles{a}}
+	loader := &packageLoader{cache: cache, opts: &options{suffix: ".go"}, order: []*packageFiles{a, b}}
+	loader.markDirty()
+	return a, b
+}
+
+func TestCacheDirty(t *testing.T) {
+	chdirTemp(t)
+
+	cache := &buildCache{Files: map[string]string{}}
+	a, b := markPackages(cache, "a")
+	if !a.dirty || !b.dirty {
+		t.Fatal("wait dirty packages without cache")
+	}
+
+	// as after a successful generation
+	for _, pkg := range []*packageFiles{a, b} {
+		for _, filePath := range pkg.outputs() {
+			cache.Files[filePath] = pkg.key
+			writeFile(t, (&options{suffix: ".go"}).outputPath(filePath), "package x\n")
+		}
+	}
+	if a, b = markPackages(cache, "a"); a.dirty || b.dirty || a.needed || b.needed {
+		t.Error("wait up to date packages")
+	}
+
+	// the change of a dependency propagates to its dependents
+	if a, b = markPackages(cache, "changed"); !a.dirty || !b.dirty {
+		t.Errorf("wait dirty packages after a change of a, got %t and %t", a.dirty, b.dirty)
+	}
+
+	// a missing output regenerates its package, the inference needs its dependencies
+	if err := os.Remove("b/b.go"); err != nil {
+		t.Fatal(err)
+	}
+	if a, b = markPackages(cache, "a"); a.dirty || !a.needed || !b.dirty {
+		t.Errorf("wait b dirty and a needed, got %t, %t and %t", a.dirty, a.needed, b.dirty)
+	}
+}
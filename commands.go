@@ -0,0 +1,312 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dvaumoron/foresee/builtins/debug"
+	"github.com/dvaumoron/foresee/builtins/eval"
+	"github.com/dvaumoron/foresee/parser"
+	"github.com/dvaumoron/foresee/types"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(flags *flag.FlagSet, args []string) int
+}
+
+var commands = []command{
+	{name: "build", summary: "generate the Go files (default command)", run: buildCommand},
+	{name: "check", summary: "report the errors without writing files", run: checkCommand},
+	{name: "fmt", summary: "normalize the layout of the foresee files", run: fmtCommand},
+	{name: "expand", summary: "print the files after macro expansion", run: expandCommand},
+	{name: "run", summary: "build then go run a package directory", run: runGoCommand},
+	{name: "watch", summary: "build again on each change of the foresee files", run: watchCommand},
+}
+
+// return the exit code, a first argument which is not a command is handled by build
+// (file paths and flags keep working without command)
+func runCommand(args []string) int {
+	if len(args) != 0 {
+		switch args[0] {
+		case "help", "-h", "-help", "--help":
+			// "help command" give the flags of the command
+			if len(args) > 1 {
+				if cmd, ok := findCommand(args[1]); ok {
+					return cmd.run(flag.NewFlagSet(cmd.name, flag.ContinueOnError), []string{"-h"})
+				}
+
+				fmt.Fprintln(os.Stderr, "Unknown command", args[1])
+				usage()
+				return exitUsage
+			}
+			usage()
+			return 0
+		}
+	}
+
+	name := "build"
+	if len(args) != 0 && !strings.HasPrefix(args[0], "-") && !strings.HasSuffix(args[0], fileExt) {
+		name, args = args[0], args[1:]
+	}
+
+	if cmd, ok := findCommand(name); ok {
+		return cmd.run(flag.NewFlagSet(name, flag.ContinueOnError), args)
+	}
+
+	fmt.Fprintln(os.Stderr, "Unknown command", name)
+	usage()
+	return exitUsage
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage : foresee [command] [flags] [files or directories]")
+	fmt.Fprintln(os.Stderr, "Commands :")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "  %-8s %s\n", "help", "print this help, or the flags of a command")
+}
+
+// help is not a failure, opts is validated when not nil
+func parseFlags(flags *flag.FlagSet, args []string, opts *options) (int, bool) {
+	switch err := flags.Parse(args); {
+	case errors.Is(err, flag.ErrHelp):
+		return 0, false
+	case err != nil:
+		return exitUsage, false
+	}
+
+	if opts != nil {
+		if err := opts.validate(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error in flags :", err)
+			return exitUsage, false
+		}
+	}
+	return 0, true
+}
+
+func buildCommand(flags *flag.FlagSet, args []string) int {
+	return processCommand(flags, args, &options{})
+}
+
+func checkCommand(flags *flag.FlagSet, args []string) int {
+	return processCommand(flags, args, &options{check: true})
+}
+
+func processCommand(flags *flag.FlagSet, args []string, opts *options) int {
+	opts.register(flags, !opts.check)
+	if code, ok := parseFlags(flags, args, opts); !ok {
+		return code
+	}
+	if !loadGoMod() {
+		return exitFailure
+	}
+	return process(opts.sources(flags.Args()), opts)
+}
+
+func process(filePaths []string, opts *options) int {
+	if failed := processPackages(filePaths, opts); failed != 0 {
+		fmt.Fprintln(os.Stderr, failed, "file(s) failed")
+		return exitFailure
+	}
+	return 0
+}
+
+func fmtCommand(flags *flag.FlagSet, args []string) int {
+	list := flags.Bool("l", false, "list the files needing formatting instead of rewriting them (fail when there is some)")
+	verbose := flags.Bool("v", false, "print the rewritten files")
+	if code, ok := parseFlags(flags, args, nil); !ok {
+		return code
+	}
+
+	filePaths := flags.Args()
+	if len(filePaths) == 0 {
+		filePaths = walkSources()
+	} else {
+		filePaths = expandDirs(filePaths)
+	}
+
+	code := 0
+	for _, filePath := range filePaths {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error while opening", filePath, ":", err)
+			code = exitFailure
+			continue
+		}
+
+		formatted, err := parser.Format(filePath, bytes.NewReader(data))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error while formatting", filePath, ":", err)
+			code = exitFailure
+			continue
+		}
+		if bytes.Equal(data, formatted) {
+			continue
+		}
+
+		if *list {
+			fmt.Println(filePath)
+			code = exitFailure
+			continue
+		}
+		if err = os.WriteFile(filePath, formatted, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, "Error while writing", filePath, ":", err)
+			code = exitFailure
+			continue
+		}
+		if *verbose {
+			fmt.Fprintln(os.Stderr, "Formatted", filePath)
+		}
+	}
+	return code
+}
+
+// print the expanded forms on the standard output (a comment line precede the forms of each file)
+func expandCommand(flags *flag.FlagSet, args []string) int {
+	if code, ok := parseFlags(flags, args, nil); !ok {
+		return code
+	}
+	if !loadGoMod() {
+		return exitFailure
+	}
+
+	filePaths := flags.Args()
+	if len(filePaths) == 0 {
+		filePaths = walkSources()
+	} else {
+		filePaths = expandDirs(filePaths)
+	}
+
+	code := 0
+	p := parser.New()
+	for _, filePath := range filePaths {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error while opening", filePath, ":", err)
+			code = exitFailure
+			continue
+		}
+
+		parsed, err := p.Parse(filePath, bytes.NewReader(data))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error while parsing", filePath, ":", err)
+			code = exitFailure
+			continue
+		}
+
+		expanded, err := eval.ExpandMacro(parsed)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error while expanding", filePath, ":", err)
+			code = exitFailure
+			continue
+		}
+
+		var builder strings.Builder
+		builder.WriteString("# ")
+		builder.WriteString(filePath)
+		builder.WriteByte('\n')
+		forms := expanded.Load(types.NewList(types.Integer(1))).(*types.List) // without file header
+		for form := range forms.Iter() {
+			form.Eval(debug.DebugEnvironment{}).Render(&builder)
+			builder.WriteByte('\n')
+		}
+		fmt.Print(builder.String())
+	}
+	return code
+}
+
+// foresee run [flags] [dir [arguments]], every foresee file of the module is built before,
+// the exit code of the program is kept (go run would replace it)
+func runGoCommand(flags *flag.FlagSet, args []string) int {
+	opts := &options{}
+	opts.register(flags, true)
+	if code, ok := parseFlags(flags, args, opts); !ok {
+		return code
+	}
+	if !loadGoMod() {
+		return exitFailure
+	}
+	if code := process(opts.sources(nil), opts); code != 0 {
+		return code
+	}
+
+	target, programArgs := ".", flags.Args()
+	if len(programArgs) != 0 {
+		target, programArgs = programArgs[0], programArgs[1:]
+	}
+	target = "." + string(filepath.Separator) + filepath.Join(opts.outputDir, target)
+
+	tempDir, err := os.MkdirTemp("", "foresee-run")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error while building", target, ":", err)
+		return exitFailure
+	}
+	defer os.RemoveAll(tempDir)
+
+	program := filepath.Join(tempDir, "program")
+	build := exec.Command("go", "build", "-o", program, target)
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err = build.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error while building", target, ":", err)
+		return exitFailure
+	}
+
+	cmd := exec.Command(program, programArgs...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err = cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintln(os.Stderr, "Error while running", target, ":", err)
+		return exitFailure
+	}
+	return 0
+}
+
+func watchCommand(flags *flag.FlagSet, args []string) int {
+	opts := &options{}
+	opts.register(flags, true)
+	interval := flags.Duration("interval", time.Second, "delay between two checks of the files")
+	if code, ok := parseFlags(flags, args, opts); !ok {
+		return code
+	}
+	if !loadGoMod() {
+		return exitFailure
+	}
+
+	watch(opts, *interval, flags.Args())
+	return 0
+}
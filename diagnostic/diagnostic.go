@@ -0,0 +1,100 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package diagnostic
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dvaumoron/foresee/types"
+)
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+type Severity uint8
+
+func (s Severity) String() string {
+	if s == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+type Diagnostic struct {
+	Span     types.Span
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprint(d.Span, " : ", d.Severity, " : ", d.Message)
+}
+
+type List []Diagnostic
+
+func (l List) HasErrors() bool {
+	for _, d := range l {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// return nil when there is no diagnostic with Error severity
+func (l List) Err() error {
+	if !l.HasErrors() {
+		return nil
+	}
+	return l
+}
+
+func (l List) Error() string {
+	var builder strings.Builder
+	for index, d := range l {
+		if index != 0 {
+			builder.WriteByte('\n')
+		}
+		builder.WriteString(d.Error())
+	}
+	return builder.String()
+}
+
+// Safe for concurrent use.
+type Collector struct {
+	mutex       sync.Mutex
+	diagnostics List
+}
+
+func (c *Collector) Add(span types.Span, severity Severity, message string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.diagnostics = append(c.diagnostics, Diagnostic{Span: span, Severity: severity, Message: message})
+}
+
+func (c *Collector) AddError(span types.Span, err error) {
+	c.Add(span, Error, err.Error())
+}
+
+func (c *Collector) Diagnostics() List {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append(List(nil), c.diagnostics...)
+}
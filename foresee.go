@@ -16,52 +16,168 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/dvaumoron/foresee/builtins/compile"
 	"github.com/dvaumoron/foresee/builtins/eval"
 	"github.com/dvaumoron/foresee/builtins/names"
-	"github.com/dvaumoron/foresee/infer"
-	"github.com/dvaumoron/foresee/parser"
 	"github.com/dvaumoron/foresee/types"
 )
 
+const fileExt = ".fc"
+
+var (
+	errNotGenerated = errors.New("existing file without the generated code header, remove it to allow the generation")
+	errOutputImport = errors.New("output directory outside of the module, the generated packages can not import each other")
+	errSuffix       = errors.New("suffix must end with .go")
+)
+
 const (
-	fileExt    = ".fc"
-	fileExtLen = len(fileExt)
+	exitFailure = 1 // some files failed
+	exitUsage   = 2 // wrong command line
 )
 
 //go:generate gennames -output "builtins/compile/hints.go" -package "compile" -name "standardLibraryHints" -standard -novendor -path "./..."
 
 func main() {
-	if !loadGoMod() {
-		return
+	os.Exit(runCommand(os.Args[1:]))
+}
+
+// settings shared by the commands processing packages
+type options struct {
+	jobs      int
+	force     bool
+	check     bool // stop before writing files
+	verbose   bool
+	outputDir string
+	suffix    string // replace the foresee extension in the generated file names
+	include   globList
+	exclude   globList
+}
+
+func (o *options) register(flags *flag.FlagSet, generate bool) {
+	flags.IntVar(&o.jobs, "j", runtime.NumCPU(), "number of files processed in parallel")
+	flags.BoolVar(&o.verbose, "v", false, "print the handled files")
+	flags.Var(&o.include, "include", "glob matching the package directories to handle (repeatable)")
+	flags.Var(&o.exclude, "exclude", "glob matching the package directories to skip (repeatable)")
+	if generate {
+		flags.BoolVar(&o.force, "force", false, "generate every file, ignoring the cache")
+		flags.StringVar(&o.outputDir, "o", "", "root directory receiving the generated files, mirroring the sources (default next to them)")
+		flags.StringVar(&o.suffix, "suffix", ".go", "end of the generated file names (like .fc.go)")
+	}
+}
+
+func (o *options) validate() error {
+	if o.suffix == "" {
+		o.suffix = ".go"
+	}
+	if !strings.HasSuffix(o.suffix, ".go") {
+		return errSuffix
 	}
+	return nil
+}
 
-	if len(os.Args) > 1 {
-		for _, filePath := range os.Args[1:] {
-			processFile(filePath)
+// the listed files (or the ones of the listed directories) or the ones found walking the current directory,
+// in the selected packages
+func (o *options) sources(filePaths []string) []string {
+	if len(filePaths) == 0 {
+		if o.verbose {
+			fmt.Fprintln(os.Stderr, "No files listed, walking current directory")
 		}
-		return
+		filePaths = walkSources()
+	} else {
+		filePaths = expandDirs(filePaths)
 	}
 
-	fmt.Println("No files listed, walking current directory")
-	filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
-		if err == nil && !d.IsDir() && path[len(path)-fileExtLen:] == fileExt {
-			processFile(path)
+	var selected []string
+	for _, filePath := range filePaths {
+		if o.selected(filepath.Dir(filePath)) {
+			selected = append(selected, filePath)
 		}
+	}
+	return selected
+}
+
+// the packages not selected are still inferred when needed by others
+func (o *options) selected(dir string) bool {
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	return (len(o.include) == 0 || o.include.match(dir)) && !o.exclude.match(dir)
+}
+
+func (o *options) outputPath(filePath string) string {
+	if dotIndex := strings.LastIndexByte(filePath, '.'); dotIndex != -1 {
+		filePath = filePath[:dotIndex]
+	}
+	return filepath.Join(o.outputDir, filePath+o.suffix)
+}
+
+type globList []string
+
+func (g *globList) String() string {
+	return strings.Join(*g, ",")
+}
+
+func (g *globList) Set(value string) error {
+	if _, err := path.Match(value, ""); err != nil {
 		return err
+	}
+	*g = append(*g, value)
+	return nil
+}
+
+func (g globList) match(dir string) bool {
+	for _, pattern := range g {
+		if ok, _ := path.Match(pattern, dir); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// replace the directories by the foresee files they contain (like the go tool, without recursion)
+func expandDirs(paths []string) []string {
+	var filePaths []string
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			dirFiles, _ := filepath.Glob(filepath.Join(path, "*"+fileExt))
+			filePaths = append(filePaths, dirFiles...)
+			continue
+		}
+		filePaths = append(filePaths, path)
+	}
+	return filePaths
+}
+
+// foresee files in the current directory and its sub directories (except testdata, like the go tool)
+func walkSources() []string {
+	var filePaths []string
+	filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case d.IsDir() && d.Name() == "testdata":
+			return filepath.SkipDir
+		case !d.IsDir() && strings.HasSuffix(path, fileExt):
+			filePaths = append(filePaths, path)
+		}
+		return nil
 	})
+	return filePaths
 }
 
 func loadGoMod() bool {
 	goModFile, err := os.Open("go.mod")
 	if err != nil {
-		fmt.Println("Error while reading go.mod :", err)
+		fmt.Fprintln(os.Stderr, "Error while reading go.mod :", err)
 		return false
 	}
 	defer goModFile.Close()
@@ -70,12 +186,12 @@ func loadGoMod() bool {
 	scanner := bufio.NewScanner(goModFile)
 	if scanner.Scan() {
 		if moduleName, ok = strings.CutPrefix(scanner.Text(), "module "); !ok {
-			fmt.Println("Error while parsing go.mod : should start with module declaration line")
+			fmt.Fprintln(os.Stderr, "Error while parsing go.mod : should start with module declaration line")
 			return false
 		}
 	}
 	if err = scanner.Err(); err != nil {
-		fmt.Println("Error while parsing go.mod :", err)
+		fmt.Fprintln(os.Stderr, "Error while parsing go.mod :", err)
 		return false
 	}
 	if ok = moduleName != ""; ok {
@@ -85,51 +201,85 @@ func loadGoMod() bool {
 	return ok
 }
 
-func parseFile(filePath string) (*types.List, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
+// return the rendered file, nil when it could not be generated (messages are written to out)
+func generateFile(out io.Writer, filePath string, infered *types.List, opts *options) []byte {
+	outputPath := opts.outputPath(filePath)
+	compiled, diagnostics := compile.Compile(infered, lineFile(outputPath, filePath))
+	for _, d := range diagnostics {
+		fmt.Fprintln(out, d)
 	}
-	defer file.Close()
-
-	return parser.Parse(file)
-}
-
-func processFile(filePath string) {
-	parsed, err := parseFile(filePath)
-	if err != nil {
-		fmt.Println("Error while opening and parsing", filePath, ":", err)
-		return
+	if diagnostics.HasErrors() {
+		fmt.Fprintln(out, "Error while compiling", filePath, ": no file written")
+		return nil
 	}
 
-	expanded, err := eval.ExpandMacro(parsed)
-	if err != nil {
-		fmt.Println("Error while expanding", filePath, ":", err)
-		return
+	var outputdata bytes.Buffer
+	if err := compiled.Render(&outputdata); err != nil {
+		fmt.Fprintln(out, "Error while rendering", outputPath, ":", err)
+		return nil
 	}
-
-	// TODO manage inference across multiple file
-	infered, err := infer.InferTypes(expanded)
-	if err != nil {
-		fmt.Println("Error while infering", filePath, ":", err)
-		return
+	if opts.check {
+		if opts.verbose {
+			fmt.Fprintln(out, "Checked", filePath)
+		}
+		return outputdata.Bytes()
 	}
 
-	var outputdata bytes.Buffer
-	outputPath := computeOutputPath(filePath)
-	if err = compile.Compile(infered).Render(&outputdata); err != nil {
-		fmt.Println("Error while rendering", outputPath, ":", err)
-		return
+	if err := checkOverwrite(outputPath); err != nil {
+		fmt.Fprintln(out, "Error while writing", outputPath, ":", err)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		fmt.Fprintln(out, "Error while writing", outputPath, ":", err)
+		return nil
 	}
+	if err := os.WriteFile(outputPath, outputdata.Bytes(), 0644); err != nil {
+		fmt.Fprintln(out, "Error while writing", outputPath, ":", err)
+		return nil
+	}
+	if opts.verbose {
+		fmt.Fprintln(out, "Generated", outputPath)
+	}
+	return outputdata.Bytes()
+}
 
-	if err = os.WriteFile(outputPath, outputdata.Bytes(), 0644); err != nil {
-		fmt.Println("Error while writing", outputPath, ":", err)
+// the go tools read the file name of a line directive relatively to the directory of the generated file,
+// never an absolute path (the generated file would depend on the machine)
+func lineFile(outputPath string, filePath string) string {
+	outputDir, err := filepath.Abs(filepath.Dir(outputPath))
+	absPath, err2 := filepath.Abs(filePath)
+	if err == nil && err2 == nil {
+		if relPath, err := filepath.Rel(outputDir, absPath); err == nil {
+			return filepath.ToSlash(relPath)
+		}
 	}
+	return filepath.Base(filePath) // the default output is beside the source
 }
 
-func computeOutputPath(filePath string) string {
-	if dotIndex := strings.LastIndexByte(filePath, '.'); dotIndex != -1 {
-		filePath = filePath[:dotIndex]
+// refuse to replace a hand written file
+func checkOverwrite(outputPath string) error {
+	file, err := os.Open(outputPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// the header is before the package clause
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == compile.GeneratedHeader {
+			return nil
+		}
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
 	}
-	return filePath + ".go"
+	return errNotGenerated
 }
@@ -0,0 +1,94 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dvaumoron/foresee/infer"
+	"github.com/dvaumoron/foresee/parser"
+)
+
+// generate the file p.fc with opts, return the messages
+func generateSource(t *testing.T, opts *options) (string, bool) {
+	t.Helper()
+
+	l, err := parser.New().Parse("p.fc", strings.NewReader("package p\n\nvar a 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	infered, err := infer.InferTypes(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	ok := generateFile(&out, "p.fc", infered, opts) != nil
+	return out.String(), ok
+}
+
+func TestOverwrite(t *testing.T) {
+	chdirTemp(t)
+
+	opts := &options{suffix: ".go"}
+	writeFile(t, "p.go", "package p\n\nvar a = 2\n")
+	if out, ok := generateSource(t, opts); ok || !strings.Contains(out, errNotGenerated.Error()) {
+		t.Errorf("wait a refusal to replace the hand written file, got %q", out)
+	}
+
+	// a previous generation can be replaced
+	if err := os.Remove("p.go"); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if out, ok := generateSource(t, opts); !ok {
+			t.Fatalf("wait a generated file, got %q", out)
+		}
+	}
+}
+
+func TestSuffix(t *testing.T) {
+	chdirTemp(t)
+
+	opts := &options{suffix: ".fc.go"}
+	if err := opts.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if out, ok := generateSource(t, opts); !ok {
+		t.Fatalf("wait a generated file, got %q", out)
+	}
+	if _, err := os.Stat("p.fc.go"); err != nil {
+		t.Error(err)
+	}
+
+	if err := (&options{suffix: ".txt"}).validate(); err != errSuffix {
+		t.Errorf("wait a suffix error, got %v", err)
+	}
+}
+
+func TestDirectoryArgument(t *testing.T) {
+	chdirTemp(t)
+
+	writeFile(t, "a/x.fc", "package a\n")
+	writeFile(t, "a/y.fc", "package a\n")
+	writeFile(t, "a/b/z.fc", "package b\n")
+	sources := (&options{}).sources([]string{"a", "a/b/z.fc"})
+	if want := []string{"a/x.fc", "a/y.fc", "a/b/z.fc"}; !slices.Equal(sources, want) {
+		t.Errorf("got %v, want %v", sources, want)
+	}
+}
@@ -0,0 +1,1152 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package infer
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/dvaumoron/foresee/builtins/names"
+	"github.com/dvaumoron/foresee/types"
+)
+
+var (
+	errDeclaration = errors.New("unhandled declaration")
+	errImport      = errors.New("can not import")
+	errPackage     = errors.New("wait the same package name in every file, found")
+	errUnknownType = errors.New("unknown type")
+)
+
+// part of the package inferred with the other members of its dependency cycle
+type unit interface {
+	declare(i *inferer)    // build the declared types (with variables for the missing ones)
+	check(i *inferer)      // generate constraints from the bodies
+	generalize(i *inferer) // turn the variables left into type parameters
+	defines(yield func(string))
+	references(yield func(string))
+}
+
+// local named type
+type typeDecl struct {
+	name        string
+	list        *types.List
+	params      []*typeParam
+	underlying  term
+	methods     map[string]*funcDecl
+	guessed     map[string]struct{} // fields declared with "?"
+	generalized bool
+	file        *fileState
+	path        string // import path of the package, empty while it is inferred
+}
+
+func (d *typeDecl) instantiateUnderlying(args []term) term {
+	return substitute(d.underlying, mappingOf(d.params, args))
+}
+
+// nil args give a reference to the declaration itself (used inside its unit)
+func (d *typeDecl) instantiate(i *inferer) *namedTerm {
+	named := &namedTerm{name: d.name, decl: d}
+	if d.generalized && len(d.params) != 0 {
+		named.args = i.freshArgs(d.params)
+	}
+	return named
+}
+
+func mappingOf(params []*typeParam, args []term) map[*typeParam]term {
+	if len(params) != len(args) {
+		return nil
+	}
+
+	mapping := make(map[*typeParam]term, len(params))
+	for index, param := range params {
+		if args[index] != param {
+			mapping[param] = args[index]
+		}
+	}
+	return mapping
+}
+
+// a type with its methods
+type typeGroup struct {
+	decl    *typeDecl
+	methods []*funcDecl
+}
+
+func (g *typeGroup) declare(i *inferer) {
+	i.file = g.decl.file
+	g.decl.underlying = i.typeDefinition(g.decl)
+	for _, method := range g.methods {
+		method.declare(i)
+	}
+}
+
+func (g *typeGroup) check(i *inferer) {
+	for _, method := range g.methods {
+		method.check(i)
+	}
+}
+
+func (g *typeGroup) generalize(i *inferer) {
+	decl := g.decl
+	i.file = decl.file
+	var vars []*typeVar
+	collectFreeVars(decl.underlying, &vars)
+	vars = defaultLiterals(vars)
+	params := i.generalizeVars(vars, decl.params)
+	decl.params = append(decl.params, params...)
+	decl.generalized = true
+
+	if len(params) != 0 {
+		i.onEnd(func() {
+			decl.list.Store(types.Integer(1), renderGenericName(decl.name, decl.params))
+		})
+	}
+
+	for _, method := range g.methods {
+		method.generalize(i)
+	}
+}
+
+func (g *typeGroup) defines(yield func(string)) {
+	yield(g.decl.name)
+	for _, method := range g.methods {
+		yield(method.name)
+	}
+	// usage of field should wait the inference of its type
+	for name := range g.decl.guessed {
+		yield(name)
+	}
+}
+
+func (g *typeGroup) references(yield func(string)) {
+	walkIdentifiers(g.decl.list, yield)
+	for _, method := range g.methods {
+		method.references(yield)
+	}
+}
+
+// function or method
+type funcDecl struct {
+	name        string
+	list        *types.List
+	recv        *typeDecl // nil for function (or method of an unknown type)
+	recvName    string
+	recvType    types.Object
+	params      []*typeParam
+	paramNames  []string
+	sig         *funcTerm
+	bodyIndex   int
+	generalized bool
+	sc          *scope
+	file        *fileState
+}
+
+// ready to use signature (instantiated when generic)
+func (d *funcDecl) instantiate(i *inferer) *funcTerm {
+	sig, _ := d.instantiateExplicit(i)
+	return sig
+}
+
+// same as instantiate, give the type arguments too when the parameters of the signature can not infer them all
+func (d *funcDecl) instantiateExplicit(i *inferer) (*funcTerm, []term) {
+	if !d.generalized || len(d.params) == 0 {
+		return d.sig, nil
+	}
+
+	args := i.freshArgs(d.params)
+	sig := substituteFunc(d.sig, mappingOf(d.params, args))
+	var inferable []*typeParam
+	for _, param := range d.sig.params {
+		collectParams(param, &inferable)
+	}
+	if len(inferable) == len(d.params) {
+		return sig, nil
+	}
+	return sig, args
+}
+
+func (d *funcDecl) isMethod() bool {
+	return d.recvType != nil
+}
+
+func (d *funcDecl) declare(i *inferer) {
+	i.file = d.file
+	d.sc = newScope(nil)
+	index := 2
+	if d.isMethod() {
+		recvList, _ := d.list.LoadInt(1).(*types.List)
+		recvIndex := recvList.Size() - 1
+		i.bindReceiverParams(d.recv, d.recvType, d.sc)
+		d.sc.declare(d.recvName, i.typeTerm(d.recvType, d.sc, recvList, recvIndex))
+		index = 3
+	} else if genList, ok := d.list.LoadInt(1).(*types.List); ok {
+		d.params = i.declareTypeParams(genList.LoadInt(2), d.sc)
+	}
+
+	d.sig = &funcTerm{}
+	paramList, _ := d.list.LoadInt(index).(*types.List)
+	d.paramNames, d.sig.params, d.sig.variadic = i.parameters(paramList, d.sc)
+
+	d.bodyIndex = index + 1
+	fn := &funcContext{}
+	if i.isResultForm(d.list.LoadInt(d.bodyIndex)) {
+		d.sig.results = i.results(d.list, d.bodyIndex, d.sc, fn)
+		d.bodyIndex++
+	}
+
+	fn.results = d.sig.results
+	d.sc.fn = fn
+	for index, name := range d.paramNames {
+		d.sc.declare(name, d.sig.params[index])
+	}
+}
+
+// make the names used in "(gen name (list T...))" receiver refer to the type parameters
+func (i *inferer) bindReceiverParams(decl *typeDecl, recvType types.Object, sc *scope) {
+	casted, ok := recvType.(*types.List)
+	if !ok || decl == nil {
+		return
+	}
+
+	switch header, _ := casted.LoadInt(0).(types.Identifier); header {
+	case names.StarId:
+		i.bindReceiverParams(decl, casted.LoadInt(1), sc)
+	case names.GenId:
+		genTypes, _ := casted.LoadInt(2).(*types.List)
+		for index := 1; index < genTypes.Size(); index++ {
+			if nameId, ok := genTypes.LoadInt(index).(types.Identifier); ok && index <= len(decl.params) {
+				sc.types[string(nameId)] = decl.params[index-1]
+			}
+		}
+	}
+}
+
+func (d *funcDecl) check(i *inferer) {
+	i.file = d.file
+	i.statements(d.list, d.bodyIndex, newScope(d.sc))
+	i.checkTries(d.sc.fn)
+}
+
+func (d *funcDecl) generalize(i *inferer) {
+	i.file = d.file
+	if d.sc.fn.guessed && !d.sc.fn.valued {
+		d.sig.results = nil // "?" without valued return
+	}
+	d.sc.fn.expandResults(d.sig)
+
+	var vars []*typeVar
+	collectFreeVars(d.sig, &vars)
+	vars = defaultLiterals(vars)
+	d.generalized = true
+	if len(vars) == 0 {
+		return
+	}
+
+	if d.isMethod() {
+		// method can not have type parameters
+		plain, guessed := splitGuessed(vars)
+		for _, v := range plain {
+			v.bound = builtinType("any")
+		}
+		i.bindGuessed(guessed)
+		return
+	}
+
+	params := i.generalizeVars(vars, d.params)
+	if len(params) == 0 {
+		return
+	}
+
+	d.params = append(d.params, params...)
+	i.onEnd(func() {
+		d.list.Store(types.Integer(1), renderGenericName(d.name, d.params))
+	})
+}
+
+func (d *funcDecl) defines(yield func(string)) {
+	if !d.isMethod() {
+		yield(d.name)
+	}
+}
+
+func (d *funcDecl) references(yield func(string)) {
+	for index := 1; index < d.list.Size(); index++ {
+		if !(d.isMethod() && index == 2) { // skip method name
+			walkIdentifiers(d.list.LoadInt(index), yield)
+		}
+	}
+}
+
+// package level var or const
+type valueDecl struct {
+	list *types.List
+	sc   *scope
+	file *fileState
+}
+
+func (v *valueDecl) declare(i *inferer) {
+	v.sc = newScope(nil)
+	v.sc.fn = &funcContext{}
+}
+
+func (v *valueDecl) check(i *inferer) {
+	i.file = v.file
+	i.definition(v.list, v.sc)
+	for name, t := range v.sc.values {
+		i.globals[name] = t
+	}
+}
+
+func (v *valueDecl) generalize(i *inferer) {}
+
+func (v *valueDecl) defines(yield func(string)) {
+	switch casted := v.list.LoadInt(1).(type) {
+	case types.Identifier:
+		yield(string(casted))
+	case *types.List:
+		if header, _ := casted.LoadInt(0).(types.Identifier); header == names.ListId {
+			yieldDefined(casted, yield)
+			return
+		}
+
+		for elem := range v.list.Iter() {
+			if line, ok := elem.(*types.List); ok {
+				yieldDefined(line.LoadInt(0), yield)
+			}
+		}
+	}
+}
+
+// handle "name" and "(list name type)"
+func yieldDefined(object types.Object, yield func(string)) {
+	switch casted := object.(type) {
+	case types.Identifier:
+		yield(string(casted))
+	case *types.List:
+		nameId, _ := casted.LoadInt(1).(types.Identifier)
+		yield(string(nameId))
+	}
+}
+
+func (v *valueDecl) references(yield func(string)) {
+	walkIdentifiers(v.list, yield)
+}
+
+func walkIdentifiers(object types.Object, yield func(string)) {
+	switch casted := object.(type) {
+	case types.Identifier:
+		yield(string(casted))
+	case *types.List:
+		for elem := range casted.Iter() {
+			walkIdentifiers(elem, yield)
+		}
+	}
+}
+
+// add each variable once, keeping the order of appearance
+// (the element of a channel and the methods of a variable follow it)
+func collectFreeVars(t term, vars *[]*typeVar) {
+	freeVars(t, func(v *typeVar) {
+		for _, known := range *vars {
+			if known == v {
+				return
+			}
+		}
+		*vars = append(*vars, v)
+		if v.elem != nil {
+			collectFreeVars(v.elem, vars)
+		}
+		for _, name := range sortedNames(v.methods) {
+			collectFreeVars(v.methods[name], vars)
+		}
+	})
+}
+
+// bind variables from untyped constants to their default type and return the other ones
+func defaultLiterals(vars []*typeVar) []*typeVar {
+	var res []*typeVar
+	for _, v := range vars {
+		if defaultType := defaultLiteralType(v.literal); defaultType != nil {
+			v.bound = defaultType
+		} else {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// bind each variable to a new type parameter ("T" when alone, "T0", "T1", etc. otherwise)
+func newTypeParams(i *inferer, vars []*typeVar, existing []*typeParam) []*typeParam {
+	used := map[string]struct{}{}
+	for _, param := range existing {
+		used[param.name] = struct{}{}
+	}
+
+	var params []*typeParam
+	counter := 0
+	for _, v := range vars {
+		name := "T"
+		if _, ok := used[name]; ok || len(vars) > 1 {
+			for {
+				name = "T" + strconv.Itoa(counter)
+				counter++
+				if _, ok := used[name]; !ok {
+					break
+				}
+			}
+		}
+		used[name] = struct{}{}
+
+		param := &typeParam{name: name, constraint: i.constraintOf(v.ops, v.elem), ops: v.ops, elem: v.elem}
+		v.bound = param
+		params = append(params, param)
+	}
+	return params
+}
+
+// first pass on the files : register declarations and build the units
+func (i *inferer) collect(files []*fileState) []unit {
+	var groups []*typeGroup
+	var funcDecls []*funcDecl
+	var units []unit
+	for _, file := range files {
+		i.file = file
+		for elem := range file.list.Iter() {
+			form, ok := elem.(*types.List)
+			if !ok {
+				continue
+			}
+
+			switch header, _ := form.LoadInt(0).(types.Identifier); header {
+			case names.Package:
+				i.checkPackageName(form)
+			case names.Import:
+				i.collectImports(form)
+			case names.Type:
+				name, _ := declaredName(form.LoadInt(1))
+				if name == "" {
+					i.report(form.Span(), errDeclaration)
+					continue
+				}
+
+				decl := &typeDecl{name: name, list: form, methods: map[string]*funcDecl{}, guessed: map[string]struct{}{}, file: file}
+				i.typeDecls[name] = decl
+				group := &typeGroup{decl: decl}
+				groups = append(groups, group)
+				units = append(units, group)
+			case names.FuncId:
+				funcDecls = append(funcDecls, &funcDecl{list: form, file: file})
+			case names.Var, names.Const:
+				units = append(units, &valueDecl{list: form, file: file})
+			}
+		}
+	}
+
+	for _, decl := range funcDecls {
+		form := decl.list
+		switch casted := form.LoadInt(1).(type) {
+		case types.Identifier:
+			decl.name = string(casted)
+		case *types.List:
+			if header, _ := casted.LoadInt(0).(types.Identifier); header == names.GenId {
+				decl.name, _ = declaredName(casted)
+				break
+			}
+
+			// method
+			methodId, _ := form.LoadInt(2).(types.Identifier)
+			decl.name = string(methodId)
+			if casted.Size() > 1 {
+				recvId, _ := casted.LoadInt(0).(types.Identifier)
+				decl.recvName = string(recvId)
+				decl.recvType = casted.LoadInt(1)
+			} else {
+				decl.recvType = casted.LoadInt(0)
+			}
+
+			if typeDecl, ok := i.typeDecls[receiverBaseName(decl.recvType)]; ok {
+				decl.recv = typeDecl
+				typeDecl.methods[decl.name] = decl
+				for _, group := range groups {
+					if group.decl == typeDecl {
+						group.methods = append(group.methods, decl)
+					}
+				}
+				continue
+			}
+		}
+
+		if decl.name == "" {
+			i.report(form.Span(), errDeclaration)
+			continue
+		}
+		if !decl.isMethod() {
+			i.funcs[decl.name] = decl
+		}
+		units = append(units, decl)
+	}
+	return units
+}
+
+// every file of a package must declare the same name
+func (i *inferer) checkPackageName(form *types.List) {
+	nameId, _ := form.LoadInt(1).(types.Identifier)
+	switch name := string(nameId); i.packageName {
+	case "":
+		i.packageName = name
+	case name:
+	default:
+		i.report(form.Span(), fmt.Errorf("%w %s and %s", errPackage, i.packageName, name))
+	}
+}
+
+// the foresee packages of the module are inferred before their importers,
+// the other imports are loaded from the export data of the Go packages
+func (i *inferer) collectImports(form *types.List) {
+	walkImports(form, func(name string, path string) {
+		i.file.imports[name] = path
+		if _, ok := fcPackages.load(path); ok {
+			return
+		}
+		if _, err := goPackages.load(path); err != nil {
+			i.report(form.Span(), fmt.Errorf("%w %q : %v", errImport, path, err))
+		}
+	})
+}
+
+// Return the paths imported by the file l (in order, without duplicate).
+func ImportPaths(l *types.List) []string {
+	var paths []string
+	for elem := range l.Iter() {
+		form, ok := elem.(*types.List)
+		if !ok {
+			continue
+		}
+		if header, _ := form.LoadInt(0).(types.Identifier); header != names.Import {
+			continue
+		}
+
+		walkImports(form, func(_ string, path string) {
+			if !slices.Contains(paths, path) {
+				paths = append(paths, path)
+			}
+		})
+	}
+	return paths
+}
+
+// Replace the imported paths of the file l which are keys of paths (in imports and in qualified types),
+// the replaced imports are named (the last element of the path could change).
+func ReplaceImports(l *types.List, paths map[string]string) {
+	replaceQualified(l, paths)
+	for index := 0; index < l.Size(); index++ {
+		form, ok := l.LoadInt(index).(*types.List)
+		if !ok {
+			continue
+		}
+		if header, _ := form.LoadInt(0).(types.Identifier); header != names.Import {
+			continue
+		}
+
+		replaced := false
+		res := types.NewList(types.Identifier(names.Import))
+		walkImports(form, func(name string, path string) {
+			newPath, ok := paths[path]
+			switch {
+			case ok:
+				res.Add(types.NewList(types.Identifier(name), types.String(newPath)))
+				replaced = true
+			case name == packageName(types.String(path)):
+				res.Add(types.NewList(types.String(path)))
+			default:
+				res.Add(types.NewList(types.Identifier(name), types.String(path)))
+			}
+		})
+		if replaced {
+			l.Store(types.Integer(index), res.SetSpan(form.Span()))
+		}
+	}
+}
+
+// rendered types refer to their package with "(get "path" name)"
+func replaceQualified(l *types.List, paths map[string]string) {
+	if header, _ := l.LoadInt(0).(types.Identifier); header == names.GetId {
+		if path, ok := l.LoadInt(1).(types.String); ok {
+			if newPath, ok := paths[string(path)]; ok {
+				l.Store(types.Integer(1), types.String(newPath))
+			}
+		}
+	}
+	for elem := range l.Iter() {
+		if casted, ok := elem.(*types.List); ok {
+			replaceQualified(casted, paths)
+		}
+	}
+}
+
+// call yield with the package name and the path of each import of form
+func walkImports(form *types.List, yield func(string, string)) {
+	next, stop := types.Pull(form.Iter())
+	defer stop()
+
+	next() // skip header
+	for importDesc := range types.Push(next) {
+		switch casted := importDesc.(type) {
+		case *types.List:
+			if casted.Size() > 1 {
+				packageId, _ := casted.LoadInt(0).(types.Identifier)
+				path, _ := casted.LoadInt(1).(types.String)
+				yield(string(packageId), string(path))
+			} else {
+				path, _ := casted.LoadInt(0).(types.String)
+				yield(packageName(path), string(path))
+			}
+			continue
+		case types.Identifier:
+			path, _ := next()
+			castedPath, _ := path.(types.String)
+			yield(string(casted), string(castedPath))
+		case types.String:
+			yield(packageName(casted), string(casted))
+		}
+		break // onliner cases so break
+	}
+}
+
+func packageName(path types.String) string {
+	casted := string(path)
+	for index := len(casted) - 1; index >= 0; index-- {
+		if casted[index] == '/' {
+			return casted[index+1:]
+		}
+	}
+	return casted
+}
+
+// handle "name" and "(gen name (list (list T constraint)...))"
+func declaredName(object types.Object) (string, *types.List) {
+	switch casted := object.(type) {
+	case types.Identifier:
+		return string(casted), nil
+	case *types.List:
+		if header, _ := casted.LoadInt(0).(types.Identifier); header == names.GenId {
+			nameId, _ := casted.LoadInt(1).(types.Identifier)
+			genDefs, _ := casted.LoadInt(2).(*types.List)
+			return string(nameId), genDefs
+		}
+	}
+	return "", nil
+}
+
+// handle "name", "(* name)", "(gen name (list ...))" and "(* (gen name (list ...)))"
+func receiverBaseName(object types.Object) string {
+	switch casted := object.(type) {
+	case types.Identifier:
+		return string(casted)
+	case *types.List:
+		switch header, _ := casted.LoadInt(0).(types.Identifier); header {
+		case names.StarId, names.GenId:
+			return receiverBaseName(casted.LoadInt(1))
+		}
+	}
+	return ""
+}
+
+// declare the parameters of "(list (list T constraint)...)" in sc
+func (i *inferer) declareTypeParams(object types.Object, sc *scope) []*typeParam {
+	genDefs, ok := object.(*types.List)
+	if !ok {
+		return nil
+	}
+
+	var params []*typeParam
+	next, stop := types.Pull(genDefs.Iter())
+	defer stop()
+
+	next() // skip ListId
+	for elem := range types.Push(next) {
+		genDef, _ := elem.(*types.List)
+		nameId, _ := genDef.LoadInt(1).(types.Identifier)
+		param := &typeParam{name: string(nameId)}
+		sc.types[param.name] = param
+		params = append(params, param)
+	}
+
+	// constraints can reference any parameter
+	for index, param := range params {
+		genDef, _ := genDefs.LoadInt(index + 1).(*types.List)
+		if constraint := genDef.LoadInt(2); constraint != types.None {
+			param.constraint = i.typeTerm(constraint, sc, nil, 0)
+		}
+	}
+	return params
+}
+
+// build the underlying type of a declaration
+func (i *inferer) typeDefinition(decl *typeDecl) term {
+	sc := newScope(nil)
+	if _, genDefs := declaredName(decl.list.LoadInt(1)); genDefs != nil {
+		decl.params = i.declareTypeParams(genDefs, sc)
+	}
+
+	switch casted := decl.list.LoadInt(2).(type) {
+	case types.Identifier:
+		switch casted {
+		case names.StructId:
+			return i.structDefinition(decl, sc)
+		case names.InterfaceId:
+			return i.interfaceDefinition(decl.list, 3, sc)
+		}
+		return i.typeTerm(casted, sc, decl.list, 2)
+	case *types.List:
+		return i.typeTerm(casted, sc, decl.list, 2)
+	}
+
+	i.report(decl.list.Span(), errDeclaration)
+	return opaqueTerm{}
+}
+
+func (i *inferer) structDefinition(decl *typeDecl, sc *scope) term {
+	res := &structTerm{}
+	for index := 3; index < decl.list.Size(); index++ {
+		fieldDesc, ok := decl.list.LoadInt(index).(*types.List)
+		if !ok {
+			continue
+		}
+
+		// "(name type tags...)" or "((list name type) tags...)", the tags are kept
+		typeList, typeIndex := fieldDesc, 1
+		if nameType, ok := fieldDesc.LoadInt(0).(*types.List); ok {
+			if header, _ := nameType.LoadInt(0).(types.Identifier); header == names.ListId {
+				typeList, typeIndex = nameType, 2
+			}
+		}
+		if typeList == fieldDesc && (fieldDesc.Size() == 1 || isTagDesc(fieldDesc.LoadInt(1))) {
+			// embedded type
+			fieldType := i.typeTerm(fieldDesc.LoadInt(0), sc, fieldDesc, 0)
+			res.fields = append(res.fields, fieldTerm{name: embeddedName(fieldType), typ: fieldType, embedded: true})
+			continue
+		}
+
+		fieldId, _ := typeList.LoadInt(typeIndex - 1).(types.Identifier)
+		fieldType := i.guessableType(typeList, typeIndex, sc)
+		if isGuess(typeList.LoadInt(typeIndex)) {
+			decl.guessed[string(fieldId)] = struct{}{}
+		}
+		res.fields = append(res.fields, fieldTerm{name: string(fieldId), typ: fieldType})
+	}
+	return res
+}
+
+// a string literal or a key:"value" pair (a field type is never one of them)
+func isTagDesc(object types.Object) bool {
+	switch casted := object.(type) {
+	case types.String:
+		return true
+	case *types.List:
+		header, _ := casted.LoadInt(0).(types.Identifier)
+		return header == names.ListId
+	}
+	return false
+}
+
+func embeddedName(t term) string {
+	switch casted := prune(t).(type) {
+	case *pointerTerm:
+		return embeddedName(casted.elem)
+	case *namedTerm:
+		return casted.name
+	}
+	return ""
+}
+
+// handle method description "(Name (param types) result)" and embedded interfaces
+func (i *inferer) interfaceDefinition(list *types.List, start int, sc *scope) term {
+	res := &interfaceTerm{methods: map[string]*funcTerm{}}
+	for index := start; index < list.Size(); index++ {
+		desc, ok := list.LoadInt(index).(*types.List)
+		if !ok {
+			continue
+		}
+
+		methodId, ok := desc.LoadInt(0).(types.Identifier)
+		switch {
+		case !ok, methodId == names.GenId, methodId == names.GetId:
+			res.embeds = append(res.embeds, i.typeTerm(desc, sc, nil, 0))
+		case methodId == names.TildeId:
+			res.embeds = append(res.embeds, opaqueTerm{})
+		default:
+			method := &funcTerm{}
+			paramTypes, _ := desc.LoadInt(1).(*types.List)
+			for elem := range paramTypes.Iter() {
+				if elem == names.ListId {
+					continue
+				}
+				if paramDesc, ok := elem.(*types.List); ok {
+					if header, _ := paramDesc.LoadInt(0).(types.Identifier); header == names.ListId {
+						elem = paramDesc.LoadInt(2) // name:type
+					}
+				}
+				method.params = append(method.params, i.typeTerm(elem, sc, nil, 0))
+			}
+			if result := desc.LoadInt(2); result != types.None {
+				method.results = []term{i.typeTerm(result, sc, nil, 0)}
+			}
+			res.methods[string(methodId)] = method
+		}
+	}
+	return res
+}
+
+func isGuess(object types.Object) bool {
+	id, _ := object.(types.Identifier)
+	return id == names.GuessMarker
+}
+
+// type at list[index], a "?" give a variable and the inferred type replace the marker
+func (i *inferer) guessableType(list *types.List, index int, sc *scope) term {
+	if !isGuess(list.LoadInt(index)) {
+		return i.typeTerm(list.LoadInt(index), sc, list, index)
+	}
+
+	v := i.fresh()
+	i.onEnd(func() {
+		list.Store(types.Integer(index), render(v))
+	})
+	return v
+}
+
+// handle a list of "name" (untyped) or "(list name type)",
+// return the names, the types and true when the last one is variadic
+func (i *inferer) parameters(paramList *types.List, sc *scope) ([]string, []term, bool) {
+	var paramNames []string
+	var paramTypes []term
+	variadic := false
+	for index := 0; index < paramList.Size(); index++ {
+		var paramType term
+		switch casted := paramList.LoadInt(index).(type) {
+		case types.Identifier:
+			paramNames = append(paramNames, string(casted))
+			v := i.fresh()
+			i.onEnd(func() {
+				paramList.Store(types.Integer(index), types.NewList(names.ListId, casted, render(v)))
+			})
+			paramType = v
+		case *types.List:
+			nameId, _ := casted.LoadInt(1).(types.Identifier)
+			paramNames = append(paramNames, string(nameId))
+			if typeDesc, ok := casted.LoadInt(2).(*types.List); ok && index == paramList.Size()-1 {
+				if header, _ := typeDesc.LoadInt(0).(types.Identifier); header == names.EllipsisId {
+					variadic = true
+					paramType = &sliceTerm{elem: i.typeTerm(typeDesc.LoadInt(1), sc, typeDesc, 1)}
+					break
+				}
+			}
+			paramType = i.guessableType(casted, 2, sc)
+		default:
+			i.report(paramList.Span(), errDeclaration)
+			paramType = opaqueTerm{}
+		}
+		paramTypes = append(paramTypes, paramType)
+	}
+	return paramNames, paramTypes, variadic
+}
+
+// true when object is the result part of a function (and not its first instruction)
+func (i *inferer) isResultForm(object types.Object) bool {
+	switch casted := object.(type) {
+	case types.NoneType, types.Identifier:
+		return true
+	case *types.List:
+		if header, _ := casted.LoadInt(0).(types.Identifier); header == names.ListId {
+			return true
+		}
+		return i.isTypeForm(casted)
+	}
+	return false
+}
+
+// result types of the function described by list (result part at index)
+func (i *inferer) results(list *types.List, index int, sc *scope, fn *funcContext) []term {
+	switch casted := list.LoadInt(index).(type) {
+	case types.Identifier:
+		if casted != names.GuessMarker {
+			return []term{i.typeTerm(casted, sc, list, index)}
+		}
+
+		// the results (or none when there is no valued return),
+		// several when the returns have several values
+		fn.guessed = true
+		v := i.fresh()
+		i.onEnd(func() {
+			switch tuple, ok := prune(v).(*tupleTerm); {
+			case !fn.valued:
+				list.Store(types.Integer(index), types.None)
+			case ok:
+				list.Store(types.Integer(index), renderList(tuple.elems))
+			default:
+				list.Store(types.Integer(index), render(v))
+			}
+		})
+		return []term{v}
+	case *types.List:
+		if header, _ := casted.LoadInt(0).(types.Identifier); header == names.ListId {
+			var results []term
+			for index := 1; index < casted.Size(); index++ {
+				if resultDesc, ok := casted.LoadInt(index).(*types.List); ok {
+					if header, _ := resultDesc.LoadInt(0).(types.Identifier); header == names.ListId {
+						// named result "(list name type)", declared in the function scope
+						nameId, _ := resultDesc.LoadInt(1).(types.Identifier)
+						result := i.guessableType(resultDesc, 2, sc)
+						sc.declare(string(nameId), result)
+						fn.named = true
+						results = append(results, result)
+						continue
+					}
+				}
+				results = append(results, i.guessableType(casted, index, sc))
+			}
+			return results
+		}
+		return []term{i.typeTerm(casted, sc, list, index)}
+	}
+	return nil
+}
+
+// same recognition as the compile package
+func (i *inferer) isTypeForm(list *types.List) bool {
+	header, _ := list.LoadInt(0).(types.Identifier)
+	if header == names.StructId || header == names.InterfaceId {
+		return true
+	}
+
+	switch list.Size() {
+	case 2:
+		switch header {
+		case names.ArrowChanId, names.ChanArrowId, names.ChanId, names.SliceId, names.FuncId, names.EllipsisId, names.StarId, names.TildeId:
+			return true
+		}
+	case 3:
+		switch header {
+		case names.FuncId, names.GenId, names.MapId, names.SliceId:
+			return true
+		case names.GetId:
+			_, ok := i.qualifier(list.LoadInt(1))
+			return ok
+		}
+	}
+	return false
+}
+
+// return the package name when object is an import usable as qualifier
+func (i *inferer) qualifier(object types.Object) (string, bool) {
+	switch casted := object.(type) {
+	case types.Identifier:
+		_, ok := i.file.imports[string(casted)]
+		return string(casted), ok
+	case types.String:
+		return packageName(casted), true
+	}
+	return "", false
+}
+
+// convert a type description,
+// parent and index locate object to add missing generic arguments (parent can be nil)
+func (i *inferer) typeTerm(object types.Object, sc *scope, parent *types.List, index int) term {
+	switch casted := object.(type) {
+	case types.Identifier:
+		return i.namedType(string(casted), sc, parent, index)
+	case *types.List:
+		return i.typeTermFromList(casted, sc, parent, index)
+	}
+
+	i.report(parent.SpanInt(index), fmt.Errorf("%w %v", errUnknownType, object))
+	return opaqueTerm{}
+}
+
+func (i *inferer) namedType(name string, sc *scope, parent *types.List, index int) term {
+	if name == names.GuessMarker {
+		return i.fresh()
+	}
+	if param, ok := sc.lookupType(name); ok {
+		return param
+	}
+
+	decl, ok := i.typeDecls[name]
+	if !ok {
+		// builtin or from another file
+		return &namedTerm{name: name}
+	}
+
+	named := decl.instantiate(i)
+	if parent != nil {
+		i.onEnd(func() {
+			if len(named.arguments()) != 0 {
+				parent.Store(types.Integer(index), render(named))
+			}
+		})
+	}
+	return named
+}
+
+func (i *inferer) typeTermFromList(list *types.List, sc *scope, parent *types.List, index int) term {
+	header, _ := list.LoadInt(0).(types.Identifier)
+	switch header {
+	case names.StructId:
+		return i.anonymousStruct(list, sc)
+	case names.InterfaceId:
+		return i.anonymousInterface(list, sc)
+	}
+
+	switch list.Size() {
+	case 2:
+		switch header {
+		case names.ArrowChanId:
+			return &chanTerm{dir: recvDir, elem: i.typeTerm(list.LoadInt(1), sc, list, 1)}
+		case names.ChanArrowId:
+			return &chanTerm{dir: sendDir, elem: i.typeTerm(list.LoadInt(1), sc, list, 1)}
+		case names.ChanId:
+			return &chanTerm{elem: i.typeTerm(list.LoadInt(1), sc, list, 1)}
+		case names.SliceId, names.EllipsisId:
+			return &sliceTerm{elem: i.typeTerm(list.LoadInt(1), sc, list, 1)}
+		case names.StarId:
+			return &pointerTerm{elem: i.typeTerm(list.LoadInt(1), sc, list, 1)}
+		case names.FuncId:
+			return i.funcType(list, sc)
+		}
+	case 3:
+		switch header {
+		case names.FuncId:
+			return i.funcType(list, sc)
+		case names.GenId:
+			named, ok := i.typeTerm(list.LoadInt(1), sc, nil, 0).(*namedTerm)
+			if !ok {
+				break
+			}
+
+			genTypes, _ := list.LoadInt(2).(*types.List)
+			res := &namedTerm{path: named.path, name: named.name, decl: named.decl, goType: named.goType, args: []term{}}
+			for index := 1; index < genTypes.Size(); index++ {
+				res.args = append(res.args, i.typeTerm(genTypes.LoadInt(index), sc, genTypes, index))
+			}
+			return res
+		case names.GetId:
+			qualifier, ok := i.qualifier(list.LoadInt(1))
+			nameId, ok2 := list.LoadInt(2).(types.Identifier)
+			if ok && ok2 {
+				path, _ := i.packagePath(list.LoadInt(1), nil)
+				res := i.packageType(path, qualifier, string(nameId), list.SpanInt(2))
+				if named, ok := res.(*namedTerm); ok && named.decl != nil && parent != nil {
+					i.onEnd(func() {
+						if len(named.arguments()) != 0 {
+							parent.Store(types.Integer(index), render(named))
+						}
+					})
+				}
+				return res
+			}
+		case names.MapId:
+			return &mapTerm{key: i.typeTerm(list.LoadInt(1), sc, list, 1), value: i.typeTerm(list.LoadInt(2), sc, list, 2)}
+		case names.SliceId:
+			elem := i.typeTerm(list.LoadInt(2), sc, list, 2)
+			switch casted := list.LoadInt(1).(type) {
+			case types.Integer:
+				return &arrayTerm{size: int64(casted), elem: elem}
+			case types.Identifier:
+				if casted == names.EllipsisId {
+					return &arrayTerm{size: -1, elem: elem}
+				}
+			}
+		}
+	}
+
+	span := list.Span()
+	if !span.IsValid() {
+		span = parent.SpanInt(index)
+	}
+	i.report(span, fmt.Errorf("%w %v", errUnknownType, renderString(list)))
+	return opaqueTerm{}
+}
+
+// handle "(struct (list name type) embedded...)"
+func (i *inferer) anonymousStruct(list *types.List, sc *scope) term {
+	res := &structTerm{}
+	for index := 1; index < list.Size(); index++ {
+		if fieldDesc, ok := list.LoadInt(index).(*types.List); ok {
+			if header, _ := fieldDesc.LoadInt(0).(types.Identifier); header == names.ListId {
+				fieldId, _ := fieldDesc.LoadInt(1).(types.Identifier)
+				res.fields = append(res.fields, fieldTerm{name: string(fieldId), typ: i.guessableType(fieldDesc, 2, sc)})
+				continue
+			}
+		}
+
+		fieldType := i.typeTerm(list.LoadInt(index), sc, list, index)
+		res.fields = append(res.fields, fieldTerm{name: embeddedName(fieldType), typ: fieldType, embedded: true})
+	}
+	return res
+}
+
+// handle "(interface (list Name (func ...)) embedded...)"
+func (i *inferer) anonymousInterface(list *types.List, sc *scope) term {
+	res := &interfaceTerm{methods: map[string]*funcTerm{}}
+	for index := 1; index < list.Size(); index++ {
+		if desc, ok := list.LoadInt(index).(*types.List); ok {
+			switch header, _ := desc.LoadInt(0).(types.Identifier); header {
+			case names.ListId:
+				methodId, _ := desc.LoadInt(1).(types.Identifier)
+				funcDesc, _ := desc.LoadInt(2).(*types.List)
+				if method, ok := i.funcType(funcDesc, sc).(*funcTerm); ok {
+					res.methods[string(methodId)] = method
+				}
+				continue
+			case names.TildeId:
+				res.embeds = append(res.embeds, opaqueTerm{})
+				continue
+			}
+		}
+		res.embeds = append(res.embeds, i.typeTerm(list.LoadInt(index), sc, list, index))
+	}
+	return res
+}
+
+// handle "(func (list params...))" and "(func (list params...) (list results...))",
+// a result could be named "(list name type)"
+func (i *inferer) funcType(list *types.List, sc *scope) term {
+	res := &funcTerm{}
+	params, _ := list.LoadInt(1).(*types.List)
+	for index := 1; index < params.Size(); index++ {
+		paramDesc := params.LoadInt(index)
+		if casted, ok := paramDesc.(*types.List); ok && index == params.Size()-1 {
+			if header, _ := casted.LoadInt(0).(types.Identifier); header == names.EllipsisId {
+				res.variadic = true
+			}
+		}
+		res.params = append(res.params, i.typeTerm(paramDesc, sc, params, index))
+	}
+
+	if returns, ok := list.LoadInt(2).(*types.List); ok {
+		for index := 1; index < returns.Size(); index++ {
+			resultList, resultIndex := returns, index
+			if resultDesc, ok := returns.LoadInt(index).(*types.List); ok {
+				if header, _ := resultDesc.LoadInt(0).(types.Identifier); header == names.ListId {
+					resultList, resultIndex = resultDesc, 2 // named result
+				}
+			}
+			res.results = append(res.results, i.typeTerm(resultList.LoadInt(resultIndex), sc, resultList, resultIndex))
+		}
+	}
+	return res
+}
@@ -0,0 +1,991 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package infer
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+
+	"github.com/dvaumoron/foresee/builtins/names"
+	"github.com/dvaumoron/foresee/types"
+)
+
+var (
+	errAssignCount = errors.New("assignment mismatch")
+	errDefinition  = errors.New("wait name with a value or name:type with an optional value")
+	errNilType     = errors.New("use of nil without a type accepting it")
+	errReturnCount = errors.New("wrong number of return values")
+	errUndefined   = errors.New("undefined :")
+	errUnexported  = errors.New("not exported :")
+	errTryResults  = errors.New("wait results ending with error for try")
+)
+
+var builtinFuncs = map[string]struct{}{
+	names.Append: {}, names.Cap: {}, "clear": {}, names.Close: {}, "complex": {}, "copy": {}, names.Delete: {},
+	"imag": {}, names.Len: {}, names.Make: {}, "max": {}, "min": {}, names.New: {}, "panic": {}, "print": {},
+	"println": {}, "real": {}, "recover": {},
+}
+
+// check instructions of list starting at index start
+func (i *inferer) statements(list *types.List, start int, sc *scope) {
+	for index := start; index < list.Size(); index++ {
+		i.statement(list.LoadInt(index), list.SpanInt(index), sc)
+	}
+}
+
+func (i *inferer) statement(object types.Object, span types.Span, sc *scope) {
+	list, ok := object.(*types.List)
+	if !ok {
+		i.expr(object, span, sc)
+		return
+	}
+
+	switch header, _ := list.LoadInt(0).(types.Identifier); header {
+	case names.Var, names.Const:
+		i.definition(list, sc)
+	case names.Assign:
+		i.assignment(list, sc, false)
+	case names.DeclareAssign:
+		i.assignment(list, sc, true)
+	case names.AddAssign, names.AndAssign, names.AndNotAssign, names.DivAssign, names.ModAssign,
+		names.MultAssign, names.OrAssign, names.SubAssign, names.XorAssign:
+		target := i.expr(list.LoadInt(1), list.SpanInt(1), sc)
+		for index := 2; index < list.Size(); index++ {
+			i.assign(target, i.expr(list.LoadInt(index), list.SpanInt(index), sc), list.Span())
+		}
+		i.require(target, operatorMasks[string(header)], list.Span())
+	case names.LShiftAssign, names.RShiftAssign:
+		target := i.expr(list.LoadInt(1), list.SpanInt(1), sc)
+		i.require(target, integerOp, list.Span())
+		i.require(i.expr(list.LoadInt(2), list.SpanInt(2), sc), integerOp, list.SpanInt(2))
+	case names.Return:
+		i.returnStatement(list, sc)
+	case names.Try:
+		i.tryStatement(list, sc)
+	case names.If:
+		inner := newScope(sc)
+		i.header(list.LoadInt(1), list.SpanInt(1), inner)
+		for index := 2; index < list.Size(); index++ {
+			i.branch(list.LoadInt(index), list.SpanInt(index), inner)
+		}
+	case names.For:
+		inner := newScope(sc)
+		i.header(list.LoadInt(1), list.SpanInt(1), inner)
+		i.statements(list, 2, newScope(inner))
+	case names.Switch:
+		inner := newScope(sc)
+		tag := i.switchHeader(list.LoadInt(1), list.SpanInt(1), inner)
+		for index := 2; index < list.Size(); index++ {
+			clause, _ := list.LoadInt(index).(*types.List)
+			i.caseClause(clause, tag, false, inner)
+		}
+	case names.Select:
+		for index := 1; index < list.Size(); index++ {
+			clause, _ := list.LoadInt(index).(*types.List)
+			i.caseClause(clause, nil, true, sc)
+		}
+	case names.Block:
+		i.statements(list, 1, newScope(sc))
+	case names.Defer, names.Go:
+		// the wrapped call (a lambda alone is called without argument)
+		i.expr(list.LoadInt(1), list.SpanInt(1), sc)
+	case names.StoreId:
+		i.store(list, sc)
+	case names.Type, names.Label, names.Goto, names.Break, names.Continue, names.Fallthrough:
+		// nothing to infer
+	default:
+		i.expr(list, list.Span(), sc)
+	}
+}
+
+// handle "(K name value)", "(K (list name type) value?)"
+// and several lines of "(name value)" or "((list name type) value?)"
+func (i *inferer) definition(list *types.List, sc *scope) {
+	switch casted := list.LoadInt(1).(type) {
+	case types.Identifier:
+		i.defineLine(list, 1, sc)
+	case *types.List:
+		if header, _ := casted.LoadInt(0).(types.Identifier); header == names.ListId {
+			i.defineLine(list, 1, sc)
+			return
+		}
+
+		for index := 1; index < list.Size(); index++ {
+			line, _ := list.LoadInt(index).(*types.List)
+			i.defineLine(line, 0, sc)
+		}
+	default:
+		i.report(list.Span(), errDefinition)
+	}
+}
+
+// name (or "(list name type)") at index followed by an optional value
+func (i *inferer) defineLine(list *types.List, index int, sc *scope) {
+	var declared term
+	name := ""
+	switch casted := list.LoadInt(index).(type) {
+	case types.Identifier:
+		name = string(casted)
+	case *types.List:
+		nameId, _ := casted.LoadInt(1).(types.Identifier)
+		name = string(nameId)
+		declared = i.guessableType(casted, 2, sc)
+	}
+
+	if index+1 < list.Size() {
+		value := i.expr(list.LoadInt(index+1), list.SpanInt(index+1), sc)
+		if declared == nil {
+			declared = value
+		} else {
+			i.assign(declared, value, list.SpanInt(index+1))
+		}
+	}
+
+	if name == "" || declared == nil {
+		i.report(list.Span(), errDefinition)
+		return
+	}
+	sc.declare(name, declared)
+}
+
+// handle "(= target value)" and "(= (targets...) values...)" (same for ":=")
+func (i *inferer) assignment(list *types.List, sc *scope, declare bool) {
+	span := list.Span()
+	var targets []types.Object
+	switch casted := list.LoadInt(1).(type) {
+	case types.Identifier:
+		targets = []types.Object{casted}
+	case *types.List:
+		switch header, _ := casted.LoadInt(0).(types.Identifier); header {
+		case names.GetId, names.LoadId, names.StarId:
+			targets = []types.Object{casted}
+		default:
+			for elem := range casted.Iter() {
+				targets = append(targets, elem)
+			}
+		}
+	}
+
+	var values []term
+	if list.Size() == 3 {
+		values = i.multiValue(list.LoadInt(2), list.SpanInt(2), len(targets), sc)
+	} else {
+		for index := 2; index < list.Size(); index++ {
+			values = append(values, i.expr(list.LoadInt(index), list.SpanInt(index), sc))
+		}
+	}
+
+	if len(values) != len(targets) {
+		i.report(span, fmt.Errorf("%w : %d variable(s) but %d value(s)", errAssignCount, len(targets), len(values)))
+		return
+	}
+
+	i.assignTargets(targets, values, span, sc, declare)
+}
+
+func (i *inferer) assignTargets(targets []types.Object, values []term, span types.Span, sc *scope, declare bool) {
+	for index, target := range targets {
+		id, isId := target.(types.Identifier)
+		if id == "_" {
+			continue
+		}
+
+		if isId && declare {
+			if known, ok := sc.values[string(id)]; ok {
+				i.assign(known, values[index], span) // already declared in this scope
+			} else {
+				sc.declare(string(id), values[index])
+			}
+			continue
+		}
+		i.assign(i.expr(target, span, sc), values[index], span)
+	}
+}
+
+// terms for a single expression giving count values
+func (i *inferer) multiValue(object types.Object, span types.Span, count int, sc *scope) []term {
+	if list, ok := object.(*types.List); ok {
+		switch header, _ := list.LoadInt(0).(types.Identifier); header {
+		case names.Range:
+			key, value := i.rangeExpr(list, sc)
+			return []term{key, value}[:min(count, 2)]
+		case names.LoadId, names.Assert:
+			if count == 2 { // comma-ok form
+				return []term{i.expr(object, span, sc), builtinType("bool")}
+			}
+		case names.Arrow:
+			if count == 2 && list.Size() == 2 {
+				return []term{i.expr(object, span, sc), builtinType("bool")}
+			}
+		}
+	}
+
+	value := i.expr(object, span, sc)
+	if count < 2 {
+		return []term{value}
+	}
+
+	values := make([]term, count)
+	for index := range values {
+		values[index] = i.fresh()
+	}
+	i.equal(value, &tupleTerm{elems: values}, span)
+	return values
+}
+
+func (i *inferer) returnStatement(list *types.List, sc *scope) {
+	fn := sc.fn
+	if fn == nil {
+		return
+	}
+
+	span := list.Span()
+	if list.Size() == 1 {
+		// naked return (the "?" case is checked by the result rewrite)
+		if len(fn.results) != 0 && !fn.named && !fn.guessed {
+			i.report(span, fmt.Errorf("%w : wait %d, get 0", errReturnCount, len(fn.results)))
+		}
+		return
+	}
+
+	var values []term
+	if list.Size() == 2 && len(fn.results) > 1 {
+		values = i.multiValue(list.LoadInt(1), list.SpanInt(1), len(fn.results), sc)
+	} else {
+		for index := 1; index < list.Size(); index++ {
+			values = append(values, i.expr(list.LoadInt(index), list.SpanInt(index), sc))
+		}
+	}
+
+	fn.valued = true
+	if fn.guessed && len(fn.results) == 1 && len(values) > 1 {
+		// the count of values give the results of "?"
+		elems := make([]term, len(values))
+		for index := range elems {
+			elems[index] = i.fresh()
+		}
+		i.equal(fn.results[0], &tupleTerm{elems: elems}, span)
+		fn.results = elems
+	}
+	if len(values) != len(fn.results) {
+		i.report(span, fmt.Errorf("%w : wait %d, get %d", errReturnCount, len(fn.results), len(values)))
+		return
+	}
+	for index, value := range values {
+		i.assign(fn.results[index], value, span)
+	}
+}
+
+// handle "(try call context?)" and "(try targets call context?)",
+// the call gives an error after the targets, like the enclosing function
+func (i *inferer) tryStatement(list *types.List, sc *scope) {
+	span := list.Span()
+	var targets []types.Object
+	callIndex := 1
+	if _, isContext := list.LoadInt(2).(types.String); list.Size() > 2 && !isContext {
+		switch casted := list.LoadInt(1).(type) {
+		case types.Identifier:
+			targets = []types.Object{casted}
+		case *types.List:
+			for elem := range casted.Iter() {
+				targets = append(targets, elem)
+			}
+		}
+		callIndex = 2
+	}
+
+	errorType := builtinType("error")
+	values := i.multiValue(list.LoadInt(callIndex), list.SpanInt(callIndex), len(targets)+1, sc)
+	if len(values) != len(targets)+1 {
+		i.report(span, fmt.Errorf("%w : %d variable(s) and an error but %d value(s)", errAssignCount, len(targets), len(values)))
+		return
+	}
+	if len(targets) == 0 {
+		i.addConstraint(tryConstraint{value: values[0], span: span})
+	} else {
+		i.assign(errorType, values[len(targets)], span)
+	}
+	i.assignTargets(targets, values, span, sc, true)
+
+	fn := sc.fn
+	if fn == nil {
+		return
+	}
+	if fn.guessed {
+		// the error is at least returned by the try
+		fn.valued = true
+		fn.tries = append(fn.tries, span)
+		return
+	}
+	if len(fn.results) == 0 {
+		i.report(span, errTryResults)
+		return
+	}
+	i.assign(fn.results[len(fn.results)-1], errorType, span)
+}
+
+// handle the header of if and for : "cond" or "(init cond post)"
+func (i *inferer) header(object types.Object, span types.Span, sc *scope) {
+	list, ok := object.(*types.List)
+	if !ok {
+		if object != types.None {
+			i.expr(object, span, sc)
+		}
+		return
+	}
+
+	if _, ok := list.LoadInt(0).(*types.List); !ok {
+		i.statement(list, span, sc)
+		return
+	}
+	i.statements(list, 0, sc)
+}
+
+func (i *inferer) branch(object types.Object, span types.Span, sc *scope) {
+	if list, ok := object.(*types.List); ok {
+		if header, _ := list.LoadInt(0).(types.Identifier); header == names.Block {
+			i.statements(list, 1, newScope(sc))
+			return
+		}
+	}
+	i.statement(object, span, newScope(sc))
+}
+
+// return the term of the switched value (nil when there is none)
+func (i *inferer) switchHeader(object types.Object, span types.Span, sc *scope) term {
+	list, ok := object.(*types.List)
+	if !ok {
+		return i.expr(object, span, sc)
+	}
+
+	if _, ok := list.LoadInt(0).(*types.List); ok {
+		// init instructions followed by the switched value
+		last := list.Size() - 1
+		for index := 0; index < last; index++ {
+			i.statement(list.LoadInt(index), list.SpanInt(index), sc)
+		}
+		return i.switchHeader(list.LoadInt(last), list.SpanInt(last), sc)
+	}
+
+	switch header, _ := list.LoadInt(0).(types.Identifier); header {
+	case names.Assign, names.DeclareAssign:
+		i.statement(list, span, sc) // type switch
+		return nil
+	}
+	return i.expr(list, span, sc)
+}
+
+// handle "(case values instructions...)" and "(default instructions...)"
+func (i *inferer) caseClause(clause *types.List, tag term, selecting bool, sc *scope) {
+	inner := newScope(sc)
+	header, _ := clause.LoadInt(0).(types.Identifier)
+	if header != names.Case {
+		i.statements(clause, 1, inner)
+		return
+	}
+
+	span := clause.SpanInt(1)
+	switch casted := clause.LoadInt(1).(type) {
+	case *types.List:
+		if selecting {
+			i.statement(casted, span, inner)
+			break
+		}
+
+		if _, ok := casted.LoadInt(0).(types.Identifier); ok {
+			i.caseValue(casted, span, tag, inner)
+			break
+		}
+		for index := 0; index < casted.Size(); index++ {
+			i.caseValue(casted.LoadInt(index), casted.SpanInt(index), tag, inner)
+		}
+	default:
+		i.caseValue(casted, span, tag, inner)
+	}
+	i.statements(clause, 2, inner)
+}
+
+func (i *inferer) caseValue(object types.Object, span types.Span, tag term, sc *scope) {
+	value := i.expr(object, span, sc)
+	if tag != nil {
+		i.equal(tag, value, span)
+	}
+}
+
+// handle "([]= container index... value)"
+func (i *inferer) store(list *types.List, sc *scope) {
+	span := list.Span()
+	last := list.Size() - 1
+	current := i.expr(list.LoadInt(1), list.SpanInt(1), sc)
+	for index := 2; index < last; index++ {
+		result := i.fresh()
+		i.addConstraint(indexConstraint{container: current, index: i.expr(list.LoadInt(index), list.SpanInt(index), sc), result: result, span: span})
+		current = result
+	}
+	i.assign(current, i.expr(list.LoadInt(last), list.SpanInt(last), sc), span)
+}
+
+func (i *inferer) expr(object types.Object, span types.Span, sc *scope) term {
+	switch casted := object.(type) {
+	case types.NoneType:
+		return i.freshNil(span)
+	case types.Boolean:
+		return i.freshLiteral(boolLiteral)
+	case types.Integer:
+		return i.freshLiteral(intLiteral)
+	case types.Float:
+		return i.freshLiteral(floatLiteral)
+	case types.Rune:
+		return i.freshLiteral(runeLiteral)
+	case types.String:
+		return i.freshLiteral(stringLiteral)
+	case types.Identifier:
+		return i.identifier(string(casted), span, sc)
+	case *types.List:
+		return i.listExpr(casted, sc)
+	}
+	return opaqueTerm{}
+}
+
+func (i *inferer) identifier(name string, span types.Span, sc *scope) term {
+	if t, ok := sc.lookupValue(name); ok {
+		return t
+	}
+	if t, ok := i.globals[name]; ok {
+		return t
+	}
+	if decl, ok := i.funcs[name]; ok && decl.sig != nil {
+		return decl.instantiate(i)
+	}
+
+	switch name {
+	case "true", "false":
+		return i.freshLiteral(boolLiteral)
+	case "nil":
+		return i.freshNil(span)
+	case "iota":
+		return i.freshLiteral(intLiteral)
+	}
+
+	// type (in type switch), package or builtin function
+	_, isFunc := i.funcs[name]
+	_, isBuiltin := builtinFuncs[name]
+	_, isPackage := i.packagePath(types.Identifier(name), sc)
+	if !(isFunc || isBuiltin || isPackage || name == "_" || i.isTypeName(name, sc)) {
+		i.report(span, fmt.Errorf("%w %s", errUndefined, name))
+	}
+	return opaqueTerm{}
+}
+
+// true when name is a type usable in sc (and not hidden by a value)
+func (i *inferer) isTypeName(name string, sc *scope) bool {
+	if _, ok := sc.lookupValue(name); ok {
+		return false
+	}
+	if _, ok := sc.lookupType(name); ok {
+		return true
+	}
+	if _, ok := i.typeDecls[name]; ok {
+		return true
+	}
+	_, ok := basicKinds[name]
+	return ok
+}
+
+func (i *inferer) listExpr(list *types.List, sc *scope) term {
+	span := list.Span()
+	header, _ := list.LoadInt(0).(types.Identifier)
+	switch header {
+	case names.GetId:
+		return i.getExpr(list, sc)
+	case names.Dot:
+		return i.methodCall(list, sc)
+	case names.LoadId:
+		return i.indexExpr(list, sc)
+	case names.Plus, names.Minus, names.StarId, names.AmpersandId, names.Slash,
+		names.Percent, names.Pipe, names.Caret, names.AndNot:
+		return i.operation(string(header), list, sc)
+	case names.LShift, names.RShift:
+		left := i.expr(list.LoadInt(1), list.SpanInt(1), sc)
+		i.require(left, integerOp, span)
+		i.require(i.expr(list.LoadInt(2), list.SpanInt(2), sc), integerOp, list.SpanInt(2))
+		return left
+	case names.Equal, names.NotEqual, names.Greater, names.GreaterEqual, names.Lesser, names.LesserEqual:
+		i.require(i.operands(list, sc), operatorMasks[string(header)], span)
+		return builtinType("bool")
+	case names.And, names.Or, names.NotId:
+		operand := i.operands(list, sc)
+		i.require(operand, logicOp, span)
+		return operand
+	case names.Arrow:
+		return i.arrowExpr(list, sc)
+	case names.Assert:
+		i.expr(list.LoadInt(1), list.SpanInt(1), sc)
+		if id, _ := list.LoadInt(2).(types.Identifier); id == names.Type {
+			return opaqueTerm{}
+		}
+		return i.typeTerm(list.LoadInt(2), sc, list, 2)
+	case names.Lambda:
+		return i.lambda(list, sc)
+	case names.Range:
+		key, _ := i.rangeExpr(list, sc)
+		return key
+	case names.EllipsisId:
+		return i.expr(list.LoadInt(1), list.SpanInt(1), sc)
+	case names.Increment, names.Decrement:
+		i.require(i.expr(list.LoadInt(1), list.SpanInt(1), sc), arithOp, span)
+		return &tupleTerm{}
+	case names.LitId, names.GenId, names.SliceId, names.MapId, names.FuncId, names.ChanId:
+		return opaqueTerm{} // type alone
+	}
+	return i.call(list, sc)
+}
+
+func (i *inferer) isLocalValue(object types.Object, sc *scope) bool {
+	id, ok := object.(types.Identifier)
+	if !ok {
+		return false
+	}
+	_, ok = sc.lookupValue(string(id))
+	return ok
+}
+
+// handle "(get a b c...)" as a.b.c
+func (i *inferer) getExpr(list *types.List, sc *scope) term {
+	span := list.Span()
+	start := 2
+	var current term
+	if path, ok := i.packagePath(list.LoadInt(1), sc); ok {
+		// member of another package
+		memberId, _ := list.LoadInt(2).(types.Identifier)
+		current = i.packageMember(path, string(memberId), list.SpanInt(2))
+		start = 3
+	} else {
+		current = i.expr(list.LoadInt(1), list.SpanInt(1), sc)
+	}
+
+	for index := start; index < list.Size(); index++ {
+		fieldId, _ := list.LoadInt(index).(types.Identifier)
+		result := i.fresh()
+		i.addConstraint(fieldConstraint{recv: current, name: string(fieldId), result: result, span: span})
+		current = result
+	}
+	return current
+}
+
+// handle "(. recv Method args...)"
+func (i *inferer) methodCall(list *types.List, sc *scope) term {
+	span := list.Span()
+	var callee term
+	methodId, _ := list.LoadInt(2).(types.Identifier)
+	if path, ok := i.packagePath(list.LoadInt(1), sc); ok {
+		callee = i.packageMember(path, string(methodId), list.SpanInt(2))
+	} else {
+		recv := i.expr(list.LoadInt(1), list.SpanInt(1), sc)
+		callee = i.fresh()
+		i.addConstraint(fieldConstraint{recv: recv, name: string(methodId), result: callee, method: true, span: span})
+	}
+
+	args, spread := i.arguments(list, 3, sc)
+	result := i.fresh()
+	i.addConstraint(callConstraint{callee: callee, args: args, spread: spread, result: result, span: span})
+	return result
+}
+
+// handle "([] a i j...)" as a[i][j] and "([] a (list lo hi))" as a[lo:hi]
+func (i *inferer) indexExpr(list *types.List, sc *scope) term {
+	span := list.Span()
+	current := i.expr(list.LoadInt(1), list.SpanInt(1), sc)
+	for index := 2; index < list.Size(); index++ {
+		var indexTerm term = opaqueTerm{}
+		slicing := false
+		if indexes, ok := list.LoadInt(index).(*types.List); ok {
+			if header, _ := indexes.LoadInt(0).(types.Identifier); header == names.ListId {
+				slicing = true
+				for index2 := 1; index2 < indexes.Size(); index2++ {
+					i.expr(indexes.LoadInt(index2), indexes.SpanInt(index2), sc)
+				}
+			}
+		}
+		if !slicing {
+			indexTerm = i.expr(list.LoadInt(index), list.SpanInt(index), sc)
+		}
+
+		result := i.fresh()
+		i.addConstraint(indexConstraint{container: current, index: indexTerm, result: result, slicing: slicing, span: span})
+		current = result
+	}
+	return current
+}
+
+// unify the operands and return the term of the first one
+func (i *inferer) operands(list *types.List, sc *scope) term {
+	var first term
+	for index := 1; index < list.Size(); index++ {
+		current := i.expr(list.LoadInt(index), list.SpanInt(index), sc)
+		if first == nil {
+			first = current
+		} else {
+			i.equal(first, current, list.Span())
+		}
+	}
+	if first == nil {
+		return opaqueTerm{}
+	}
+	return first
+}
+
+// arithmetic and bitwise operators (unary or binary)
+func (i *inferer) operation(op string, list *types.List, sc *scope) term {
+	if list.Size() != 2 {
+		operand := i.operands(list, sc)
+		i.require(operand, operatorMasks[op], list.Span())
+		return operand
+	}
+
+	operand := i.expr(list.LoadInt(1), list.SpanInt(1), sc)
+	switch op {
+	case string(names.AmpersandId):
+		return &pointerTerm{elem: operand}
+	case string(names.StarId):
+		if pointer, ok := underlying(operand).(*pointerTerm); ok {
+			return pointer.elem
+		}
+
+		elem := i.fresh()
+		i.equal(operand, &pointerTerm{elem: elem}, list.Span())
+		return elem
+	case names.Caret:
+		i.require(operand, integerOp, list.Span())
+	default: // unary + and -
+		i.require(operand, arithOp, list.Span())
+	}
+	return operand
+}
+
+// handle receive "(<- ch)" and send "(<- ch value)"
+func (i *inferer) arrowExpr(list *types.List, sc *scope) term {
+	span := list.Span()
+	channel := i.expr(list.LoadInt(1), list.SpanInt(1), sc)
+	if list.Size() == 2 {
+		return i.channelElem(channel, recvOp, span)
+	}
+
+	elem := i.channelElem(channel, sendOp, span)
+	i.assign(elem, i.expr(list.LoadInt(2), list.SpanInt(2), sc), span)
+	return &tupleTerm{}
+}
+
+func (i *inferer) rangeExpr(list *types.List, sc *scope) (term, term) {
+	key, value := i.fresh(), i.fresh()
+	container := i.expr(list.LoadInt(1), list.SpanInt(1), sc)
+	i.addConstraint(rangeConstraint{container: container, key: key, value: value, span: list.Span()})
+	return key, value
+}
+
+// handle "(lambda params results instructions...)"
+func (i *inferer) lambda(list *types.List, sc *scope) term {
+	inner := newScope(sc)
+	fn := &funcContext{}
+	sig := &funcTerm{}
+	paramList, _ := list.LoadInt(1).(*types.List)
+	var paramNames []string
+	paramNames, sig.params, sig.variadic = i.parameters(paramList, inner)
+
+	bodyIndex := 2
+	if i.isResultForm(list.LoadInt(bodyIndex)) {
+		sig.results = i.results(list, bodyIndex, inner, fn)
+		bodyIndex++
+	}
+
+	fn.results = sig.results
+	inner.fn = fn
+	for index, name := range paramNames {
+		inner.declare(name, sig.params[index])
+	}
+	i.statements(list, bodyIndex, newScope(inner))
+	i.checkTries(fn)
+	fn.expandResults(sig)
+	return sig
+}
+
+// return the argument terms and true when the last one is spread with "..."
+func (i *inferer) arguments(list *types.List, start int, sc *scope) ([]term, bool) {
+	var args []term
+	spread := false
+	for index := start; index < list.Size(); index++ {
+		arg := list.LoadInt(index)
+		if casted, ok := arg.(*types.List); ok && index == list.Size()-1 {
+			if header, _ := casted.LoadInt(0).(types.Identifier); header == names.EllipsisId {
+				spread = true
+			}
+		}
+		args = append(args, i.expr(arg, list.SpanInt(index), sc))
+	}
+	return args, spread
+}
+
+func (i *inferer) call(list *types.List, sc *scope) term {
+	span := list.Span()
+	head := list.LoadInt(0)
+	if literalType, result, ok := i.literalHead(head, sc); ok {
+		i.compositeLiteral(literalType, list, sc)
+		return result
+	}
+
+	if headList, ok := head.(*types.List); ok {
+		if header, _ := headList.LoadInt(0).(types.Identifier); header == names.InterfaceId {
+			// conversion to an anonymous interface
+			for index := 1; index < list.Size(); index++ {
+				i.expr(list.LoadInt(index), list.SpanInt(index), sc)
+			}
+			return i.typeTerm(headList, sc, list, 0)
+		}
+	}
+
+	if headId, ok := head.(types.Identifier); ok {
+		name := string(headId)
+		if i.isTypeName(name, sc) {
+			return i.conversion(name, list, sc)
+		}
+
+		_, isValue := sc.lookupValue(name)
+		_, isGlobal := i.globals[name]
+		_, isFunc := i.funcs[name]
+		if _, ok := builtinFuncs[name]; ok && !(isValue || isGlobal || isFunc) {
+			return i.builtinCall(name, list, sc)
+		}
+	}
+
+	var callee term
+	if decl := i.calledFunc(head, sc); decl != nil {
+		sig, typeArgs := decl.instantiateExplicit(i)
+		callee = sig
+		if len(typeArgs) != 0 {
+			// Go only infers type parameters from the arguments
+			i.onEnd(func() {
+				list.Store(types.Integer(0), renderInstantiation(head, typeArgs))
+			})
+		}
+	} else {
+		callee = i.expr(head, list.SpanInt(0), sc)
+	}
+	args, spread := i.arguments(list, 1, sc)
+	result := i.fresh()
+	i.addConstraint(callConstraint{callee: callee, args: args, spread: spread, result: result, span: span})
+	return result
+}
+
+// declaration of the function called by head, a name or "(get package name)" (nil for other callee)
+func (i *inferer) calledFunc(head types.Object, sc *scope) *funcDecl {
+	switch casted := head.(type) {
+	case types.Identifier:
+		name := string(casted)
+		_, isGlobal := i.globals[name]
+		if decl, ok := i.funcs[name]; ok && decl.sig != nil && !i.isLocalValue(casted, sc) && !isGlobal {
+			return decl
+		}
+	case *types.List:
+		if header, _ := casted.LoadInt(0).(types.Identifier); header != names.GetId || casted.Size() != 3 {
+			return nil
+		}
+		if path, ok := i.packagePath(casted.LoadInt(1), sc); ok {
+			memberId, _ := casted.LoadInt(2).(types.Identifier)
+			if pkg, ok := fcPackages.load(path); ok && token.IsExported(string(memberId)) {
+				return pkg.funcs[string(memberId)]
+			}
+		}
+	}
+	return nil
+}
+
+// detect literal header like "(lit T)", "(& T)", "(gen T (list ...))", "(slice T)", "(map K V)" or "(struct ...)",
+// return the type of the literal and the type of the expression
+func (i *inferer) literalHead(head types.Object, sc *scope) (term, term, bool) {
+	list, ok := head.(*types.List)
+	if !ok {
+		return nil, nil, false
+	}
+
+	switch header, _ := list.LoadInt(0).(types.Identifier); header {
+	case names.LitId:
+		literalType := i.typeTerm(list.LoadInt(1), sc, list, 1)
+		return literalType, literalType, true
+	case names.AmpersandId:
+		if list.Size() != 2 {
+			break
+		}
+
+		switch casted := list.LoadInt(1).(type) {
+		case types.Identifier:
+			if !i.isTypeName(string(casted), sc) {
+				return nil, nil, false
+			}
+		case *types.List:
+			if _, _, ok := i.literalHead(casted, sc); !ok && !i.isTypeForm(casted) {
+				return nil, nil, false
+			}
+		}
+		literalType := i.typeTerm(list.LoadInt(1), sc, list, 1)
+		return literalType, &pointerTerm{elem: literalType}, true
+	case names.GenId, names.SliceId, names.MapId, names.StructId:
+		if i.isTypeForm(list) {
+			literalType := i.typeTerm(list, sc, nil, 0)
+			return literalType, literalType, true
+		}
+	}
+	return nil, nil, false
+}
+
+// handle "(T value)" as a conversion and "(T (list field value)...)" as a composite literal
+func (i *inferer) conversion(name string, list *types.List, sc *scope) term {
+	keyed := false
+	if first, ok := list.LoadInt(1).(*types.List); ok {
+		header, _ := first.LoadInt(0).(types.Identifier)
+		keyed = header == names.ListId
+	}
+
+	target := i.typeTerm(types.Identifier(name), sc, nil, 0)
+	switch underlying(target).(type) {
+	case *structTerm, *sliceTerm, *arrayTerm, *mapTerm:
+		if keyed || list.Size() != 2 {
+			// compile need the lit marker to generate a literal instead of a call
+			i.onEnd(func() {
+				list.Store(types.Integer(0), types.NewList(names.LitId, render(target)))
+			})
+			i.compositeLiteral(target, list, sc)
+			return target
+		}
+	}
+
+	if named, ok := target.(*namedTerm); ok && named.args != nil {
+		i.onEnd(func() {
+			list.Store(types.Integer(0), render(named))
+		})
+	}
+	for index := 1; index < list.Size(); index++ {
+		i.expr(list.LoadInt(index), list.SpanInt(index), sc)
+	}
+	return target
+}
+
+// constraint the elements of the literal "(header elements...)"
+func (i *inferer) compositeLiteral(literalType term, list *types.List, sc *scope) {
+	keyed := false
+	if first, ok := list.LoadInt(1).(*types.List); ok {
+		header, _ := first.LoadInt(0).(types.Identifier)
+		keyed = header == names.ListId
+	}
+
+	underlyingType := underlying(literalType)
+	for index := 1; index < list.Size(); index++ {
+		var keyObject types.Object
+		valueObject, valueSpan := list.LoadInt(index), list.SpanInt(index)
+		if keyed {
+			elem, _ := valueObject.(*types.List)
+			keyObject, valueObject, valueSpan = elem.LoadInt(1), elem.LoadInt(2), elem.SpanInt(2)
+		}
+
+		value := i.expr(valueObject, valueSpan, sc)
+		switch casted := underlyingType.(type) {
+		case *structTerm:
+			fieldIndex := index - 1
+			if keyed {
+				fieldId, _ := keyObject.(types.Identifier)
+				fieldIndex = casted.fieldIndex(string(fieldId))
+			}
+			if fieldIndex < 0 || fieldIndex >= len(casted.fields) {
+				i.report(valueSpan, fmt.Errorf("%w %v in %v", errUnknownField, keyObject, literalType))
+				continue
+			}
+			i.assign(casted.fields[fieldIndex].typ, value, valueSpan)
+		case *sliceTerm:
+			i.literalKey(keyObject, builtinType("int"), valueSpan, sc)
+			i.assign(casted.elem, value, valueSpan)
+		case *arrayTerm:
+			i.literalKey(keyObject, builtinType("int"), valueSpan, sc)
+			i.assign(casted.elem, value, valueSpan)
+		case *mapTerm:
+			i.literalKey(keyObject, casted.key, valueSpan, sc)
+			i.assign(casted.value, value, valueSpan)
+		}
+	}
+}
+
+func (i *inferer) literalKey(keyObject types.Object, keyType term, span types.Span, sc *scope) {
+	if keyObject != nil {
+		i.assign(keyType, i.expr(keyObject, span, sc), span)
+	}
+}
+
+func (s *structTerm) fieldIndex(name string) int {
+	for index, field := range s.fields {
+		if field.name == name {
+			return index
+		}
+	}
+	return -1
+}
+
+func (i *inferer) builtinCall(name string, list *types.List, sc *scope) term {
+	span := list.Span()
+	switch name {
+	case names.Make:
+		made := i.typeTerm(list.LoadInt(1), sc, list, 1)
+		for index := 2; index < list.Size(); index++ {
+			i.expr(list.LoadInt(index), list.SpanInt(index), sc)
+		}
+		return made
+	case names.New:
+		return &pointerTerm{elem: i.typeTerm(list.LoadInt(1), sc, list, 1)}
+	}
+
+	args, spread := i.arguments(list, 1, sc)
+	switch name {
+	case names.Append:
+		if len(args) == 0 {
+			return opaqueTerm{}
+		}
+		if spread {
+			for _, arg := range args[1:] {
+				i.assign(args[0], arg, span)
+			}
+			return args[0]
+		}
+
+		for _, arg := range args[1:] {
+			elem := i.fresh()
+			i.addConstraint(indexConstraint{container: args[0], index: builtinType("int"), result: elem, span: span})
+			i.assign(elem, arg, span)
+		}
+		return args[0]
+	case names.Len, names.Cap, "copy":
+		return builtinType("int")
+	case "min", "max":
+		for _, arg := range args[1:] {
+			i.equal(args[0], arg, span)
+		}
+		if len(args) != 0 {
+			return args[0]
+		}
+	case "complex":
+		return builtinType("complex128")
+	case "real", "imag":
+		return builtinType("float64")
+	case "recover":
+		return builtinType("any")
+	}
+	return &tupleTerm{} // no value
+}
@@ -0,0 +1,462 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package infer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/importer"
+	"go/token"
+	gotypes "go/types"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/dvaumoron/foresee/builtins/compile"
+	"github.com/dvaumoron/foresee/types"
+)
+
+// packages inferred from foresee sources, by import path
+var fcPackages = packageRegistry{packages: map[string]*inferredPackage{}}
+
+var errNoExport = errors.New("no export data")
+
+// imported Go packages, shared by every inference
+var goPackages = packageCache{importer: newImporter(), packages: map[string]cachedPackage{}}
+
+// Forget the inferred and the imported packages
+// (their sources could have changed since the previous inferences).
+func ResetPackages() {
+	fcPackages.reset()
+	goPackages.reset()
+}
+
+func newImporter() gotypes.Importer {
+	return importer.ForCompiler(token.NewFileSet(), "gc", LookupExport)
+}
+
+type packageCache struct {
+	mutex    sync.Mutex
+	importer gotypes.Importer
+	packages map[string]cachedPackage
+}
+
+type cachedPackage struct {
+	pkg *gotypes.Package
+	err error // the import failed when not nil
+}
+
+// No panic with unknown path (return nil and the import error)
+func (c *packageCache) load(path string) (*gotypes.Package, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cached, ok := c.packages[path]
+	if !ok {
+		cached.pkg, cached.err = c.importer.Import(path)
+		c.packages[path] = cached
+	}
+	return cached.pkg, cached.err
+}
+
+// the importer has its own cache
+func (c *packageCache) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.importer, c.packages = newImporter(), map[string]cachedPackage{}
+}
+
+// Ask the go command for the export data of the package path
+// (module aware, unlike the default lookup of go/importer).
+func LookupExport(path string) (io.ReadCloser, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("go", "list", "-export", "-f", "{{.Export}}", path)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	exportPath := strings.TrimSpace(string(output))
+	if exportPath == "" {
+		return nil, errNoExport
+	}
+	return os.Open(exportPath)
+}
+
+type packageRegistry struct {
+	mutex    sync.RWMutex
+	packages map[string]*inferredPackage
+}
+
+// declarations of a package (generalized, so they can be instantiated by another inferer)
+type inferredPackage struct {
+	name      string
+	typeDecls map[string]*typeDecl
+	funcs     map[string]*funcDecl
+	globals   map[string]term
+}
+
+func (r *packageRegistry) store(path string, i *inferer) {
+	for _, decl := range i.typeDecls {
+		decl.path = path
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.packages[path] = &inferredPackage{name: i.packageName, typeDecls: i.typeDecls, funcs: i.funcs, globals: i.globals}
+}
+
+func (r *packageRegistry) reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.packages = map[string]*inferredPackage{}
+}
+
+func (r *packageRegistry) load(path string) (*inferredPackage, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	pkg, ok := r.packages[path]
+	return pkg, ok
+}
+
+func (p *inferredPackage) member(i *inferer, name string, span types.Span) term {
+	if !p.checkExported(i, name, span) {
+		return opaqueTerm{}
+	}
+	if decl, ok := p.funcs[name]; ok {
+		return decl.instantiate(i)
+	}
+	if t, ok := p.globals[name]; ok {
+		return t
+	}
+	if _, ok := p.typeDecls[name]; !ok {
+		i.report(span, fmt.Errorf("%w %s.%s", errUndefined, p.name, name))
+	}
+	return opaqueTerm{} // type (conversion) or unknown
+}
+
+func (p *inferredPackage) checkExported(i *inferer, name string, span types.Span) bool {
+	if token.IsExported(name) {
+		return true
+	}
+
+	_, isType := p.typeDecls[name]
+	_, isFunc := p.funcs[name]
+	_, isGlobal := p.globals[name]
+	if isType || isFunc || isGlobal {
+		i.report(span, fmt.Errorf("%w %s.%s", errUnexported, p.name, name))
+	} else {
+		i.report(span, fmt.Errorf("%w %s.%s", errUndefined, p.name, name))
+	}
+	return false
+}
+
+// import path of the package named by object (when not hidden by a value)
+func (i *inferer) packagePath(object types.Object, sc *scope) (string, bool) {
+	switch casted := object.(type) {
+	case types.Identifier:
+		name := string(casted)
+		_, isGlobal := i.globals[name]
+		_, isFunc := i.funcs[name]
+		if i.isLocalValue(casted, sc) || isGlobal || isFunc {
+			return "", false
+		}
+		if path, ok := i.file.imports[name]; ok {
+			return path, true
+		}
+		return compile.LibraryPath(name)
+	case types.String:
+		return string(casted), true
+	}
+	return "", false
+}
+
+// type of the exported value name of the package path
+func (i *inferer) packageMember(path string, name string, span types.Span) term {
+	if pkg, ok := fcPackages.load(path); ok {
+		return pkg.member(i, name, span)
+	}
+
+	pkg, err := goPackages.load(path)
+	if err != nil {
+		return opaqueTerm{} // reported with the import
+	}
+
+	switch casted := pkg.Scope().Lookup(name).(type) {
+	case nil: // export data has no unexported member
+		i.report(span, fmt.Errorf("%w %s.%s", errUndefined, pkg.Name(), name))
+	case *gotypes.Func:
+		return i.goTerm(casted.Type(), nil)
+	case *gotypes.Var:
+		return i.goTerm(casted.Type(), nil)
+	case *gotypes.Const:
+		return i.goTerm(casted.Type(), nil)
+	}
+	return opaqueTerm{} // type (conversion)
+}
+
+// type name of the Go package path (qualifier is used when the package can not be loaded)
+func (i *inferer) packageType(path string, qualifier string, name string, span types.Span) term {
+	if pkg, ok := fcPackages.load(path); ok {
+		if !pkg.checkExported(i, name, span) {
+			return opaqueTerm{}
+		}
+		if decl, ok := pkg.typeDecls[name]; ok {
+			return decl.instantiate(i)
+		}
+		i.report(span, fmt.Errorf("%w %s.%s", errUndefined, pkg.name, name))
+		return opaqueTerm{}
+	}
+
+	if pkg, err := goPackages.load(path); err == nil {
+		if typeName, ok := pkg.Scope().Lookup(name).(*gotypes.TypeName); ok {
+			return i.goTerm(typeName.Type(), nil)
+		}
+	}
+	return &namedTerm{path: qualifier, name: name}
+}
+
+// type of the field or method name of an imported type
+func (i *inferer) goMember(named *namedTerm, name string) (term, bool) {
+	origin := named.goType.Origin()
+	var recv gotypes.Type = origin
+	if !gotypes.IsInterface(origin) {
+		recv = gotypes.NewPointer(origin) // methods with both receivers
+	}
+	object, _, _ := gotypes.LookupFieldOrMethod(recv, false, nil, name)
+	switch casted := object.(type) {
+	case *gotypes.Func:
+		sig, _ := casted.Type().(*gotypes.Signature)
+		return i.goTerm(sig, goMapping(sig.RecvTypeParams(), named.args)), true
+	case *gotypes.Var:
+		return i.goTerm(casted.Type(), goMapping(origin.TypeParams(), named.args)), true
+	}
+	return nil, false
+}
+
+func goMapping(params *gotypes.TypeParamList, args []term) map[*gotypes.TypeParam]term {
+	if params.Len() != len(args) {
+		return nil
+	}
+
+	mapping := make(map[*gotypes.TypeParam]term, len(args))
+	for index, arg := range args {
+		mapping[params.At(index)] = arg
+	}
+	return mapping
+}
+
+// convert a Go type, mapping give the terms replacing type parameters
+func (i *inferer) goTerm(t gotypes.Type, mapping map[*gotypes.TypeParam]term) term {
+	switch casted := gotypes.Unalias(t).(type) {
+	case *gotypes.Basic:
+		return i.goBasic(casted)
+	case *gotypes.Named:
+		object := casted.Obj()
+		if object.Pkg() == nil {
+			return builtinType(object.Name()) // error or comparable
+		}
+
+		res := &namedTerm{path: object.Pkg().Path(), name: object.Name(), goType: casted}
+		if typeArgs := casted.TypeArgs(); typeArgs.Len() != 0 {
+			for index := range typeArgs.Len() {
+				res.args = append(res.args, i.goTerm(typeArgs.At(index), mapping))
+			}
+		} else if typeParams := casted.TypeParams(); typeParams.Len() != 0 {
+			for index := range typeParams.Len() {
+				res.args = append(res.args, i.goTerm(typeParams.At(index), mapping))
+			}
+		}
+		return res
+	case *gotypes.Pointer:
+		return &pointerTerm{elem: i.goTerm(casted.Elem(), mapping)}
+	case *gotypes.Slice:
+		return &sliceTerm{elem: i.goTerm(casted.Elem(), mapping)}
+	case *gotypes.Array:
+		return &arrayTerm{size: casted.Len(), elem: i.goTerm(casted.Elem(), mapping)}
+	case *gotypes.Map:
+		return &mapTerm{key: i.goTerm(casted.Key(), mapping), value: i.goTerm(casted.Elem(), mapping)}
+	case *gotypes.Chan:
+		dir := bothDir
+		switch casted.Dir() {
+		case gotypes.RecvOnly:
+			dir = recvDir
+		case gotypes.SendOnly:
+			dir = sendDir
+		}
+		return &chanTerm{dir: dir, elem: i.goTerm(casted.Elem(), mapping)}
+	case *gotypes.Signature:
+		return i.goFunc(casted, mapping)
+	case *gotypes.Tuple:
+		return &tupleTerm{elems: i.goTuple(casted, mapping)}
+	case *gotypes.Struct:
+		res := &structTerm{}
+		for index := range casted.NumFields() {
+			field := casted.Field(index)
+			res.fields = append(res.fields, fieldTerm{name: field.Name(), typ: i.goTerm(field.Type(), mapping), embedded: field.Embedded()})
+		}
+		return res
+	case *gotypes.Interface:
+		res := &interfaceTerm{methods: make(map[string]*funcTerm, casted.NumMethods())}
+		for index := range casted.NumMethods() {
+			method := casted.Method(index)
+			sig, _ := method.Type().(*gotypes.Signature)
+			res.methods[method.Name()] = i.goFunc(sig, mapping)
+		}
+		return res
+	case *gotypes.TypeParam:
+		if res, ok := mapping[casted]; ok {
+			return res
+		}
+	}
+	return opaqueTerm{}
+}
+
+func (i *inferer) goBasic(basic *gotypes.Basic) term {
+	switch basic.Kind() {
+	case gotypes.UntypedBool:
+		return i.freshLiteral(boolLiteral)
+	case gotypes.UntypedInt:
+		return i.freshLiteral(intLiteral)
+	case gotypes.UntypedRune:
+		return i.freshLiteral(runeLiteral)
+	case gotypes.UntypedFloat, gotypes.UntypedComplex:
+		return i.freshLiteral(floatLiteral)
+	case gotypes.UntypedString:
+		return i.freshLiteral(stringLiteral)
+	case gotypes.UntypedNil:
+		return i.freshLiteral(nilLiteral)
+	case gotypes.Invalid, gotypes.UnsafePointer:
+		return opaqueTerm{}
+	}
+	return builtinType(basic.Name())
+}
+
+// each use of a generic function get new variables for its type parameters
+func (i *inferer) goFunc(sig *gotypes.Signature, mapping map[*gotypes.TypeParam]term) *funcTerm {
+	if typeParams := sig.TypeParams(); typeParams.Len() != 0 {
+		extended := make(map[*gotypes.TypeParam]term, len(mapping)+typeParams.Len())
+		for param, t := range mapping {
+			extended[param] = t
+		}
+		for index := range typeParams.Len() {
+			v := i.fresh()
+			v.ops = goOps(typeParams.At(index).Constraint())
+			extended[typeParams.At(index)] = v
+		}
+		mapping = extended
+
+		// constraint like ~[]E give the shape of the type
+		for index := range typeParams.Len() {
+			param := typeParams.At(index)
+			if core := goCoreType(param.Constraint()); core != nil {
+				extended[param].(*typeVar).bound = i.goTerm(core, mapping)
+			}
+		}
+	}
+
+	return &funcTerm{params: i.goTuple(sig.Params(), mapping), results: i.goTuple(sig.Results(), mapping), variadic: sig.Variadic()}
+}
+
+func (i *inferer) goTuple(tuple *gotypes.Tuple, mapping map[*gotypes.TypeParam]term) []term {
+	res := make([]term, tuple.Len())
+	for index := range tuple.Len() {
+		res[index] = i.goTerm(tuple.At(index).Type(), mapping)
+	}
+	return res
+}
+
+// operator classes allowed by a Go constraint
+func goOps(constraint gotypes.Type) opMask {
+	iface, ok := constraint.Underlying().(*gotypes.Interface)
+	if !ok {
+		return 0
+	}
+
+	var ops opMask
+	if iface.IsComparable() {
+		ops = equalOp
+	}
+	kinds, ok := goKinds(iface)
+	if !ok {
+		return ops
+	}
+
+	for _, operator := range operatorKinds {
+		if kinds&^operator.kinds == 0 {
+			ops |= operator.op
+		}
+	}
+	return ops
+}
+
+// composite type of a constraint with a single term (nil otherwise)
+func goCoreType(constraint gotypes.Type) gotypes.Type {
+	iface, ok := constraint.Underlying().(*gotypes.Interface)
+	if !ok || iface.NumEmbeddeds() != 1 {
+		return nil
+	}
+
+	var single gotypes.Type
+	switch casted := iface.EmbeddedType(0).(type) {
+	case *gotypes.Union:
+		if casted.Len() == 1 {
+			single = casted.Term(0).Type()
+		}
+	default:
+		single = casted
+	}
+	if single == nil {
+		return nil
+	}
+
+	switch single.(type) {
+	case *gotypes.Slice, *gotypes.Map, *gotypes.Chan, *gotypes.Pointer, *gotypes.Array, *gotypes.Signature:
+		return single
+	}
+	return nil
+}
+
+// basic kinds of the type set of a constraint (false without restriction or with non basic type)
+func goKinds(t gotypes.Type) (kindSet, bool) {
+	switch casted := t.Underlying().(type) {
+	case *gotypes.Basic:
+		kind, known := basicKinds[canonicalName(casted.Name())]
+		return 1 << kind, known && kind != otherBasic
+	case *gotypes.Union:
+		var res kindSet
+		for index := range casted.Len() {
+			kinds, ok := goKinds(casted.Term(index).Type())
+			if !ok {
+				return 0, false
+			}
+			res |= kinds
+		}
+		return res, true
+	case *gotypes.Interface:
+		res, restricted := allKinds, false
+		for index := range casted.NumEmbeddeds() {
+			if kinds, ok := goKinds(casted.EmbeddedType(index)); ok {
+				res &= kinds
+				restricted = true
+			}
+		}
+		return res, restricted
+	}
+	return 0, false
+}
@@ -0,0 +1,226 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package infer
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// record a method called on a value of unknown type and return its type
+func (i *inferer) requireMethod(v *typeVar, name string) term {
+	if v.methods == nil {
+		v.methods = map[string]term{}
+	}
+
+	method, ok := v.methods[name]
+	if !ok {
+		method = i.fresh()
+		v.methods[name] = method
+	}
+	return method
+}
+
+// merge the methods required by v into other (when v is bound to other)
+func (i *inferer) mergeMethods(v *typeVar, other *typeVar) error {
+	for name, method := range v.methods {
+		if err := i.unify(i.requireMethod(other, name), method); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check the methods required by v against t (when v is bound to t)
+func (i *inferer) checkMethods(v *typeVar, t term) error {
+	for _, name := range sortedNames(v.methods) {
+		method := v.methods[name]
+		if selected, found := i.selectMember(t, name, 0); found {
+			if err := i.unify(method, selected); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch casted := underlying(t).(type) {
+		case opaqueTerm:
+		case *namedTerm:
+			if !isExternal(casted) {
+				return fmt.Errorf("%w %s in %v", errUnknownField, name, t)
+			}
+		case *typeParam:
+			if casted.constraint == nil {
+				return fmt.Errorf("%w %s in %v", errUnknownField, name, t)
+			}
+		default:
+			return fmt.Errorf("%w %s in %v", errUnknownField, name, t)
+		}
+		i.bindOpaque(method)
+	}
+	return nil
+}
+
+// separate the variables becoming an interface from the other ones
+func splitGuessed(vars []*typeVar) ([]*typeVar, []*typeVar) {
+	var plain, guessed []*typeVar
+	for _, v := range vars {
+		if len(v.methods) != 0 && v.ops == 0 {
+			guessed = append(guessed, v)
+		} else {
+			plain = append(plain, v)
+		}
+	}
+	return plain, guessed
+}
+
+// bind the variables to new type parameters or to synthesized interfaces
+func (i *inferer) generalizeVars(vars []*typeVar, existing []*typeParam) []*typeParam {
+	plain, guessed := splitGuessed(vars)
+	params := newTypeParams(i, plain, existing)
+	i.bindGuessed(guessed)
+	return params
+}
+
+// the variables of the methods are already bound (so start with the inner values)
+func (i *inferer) bindGuessed(guessed []*typeVar) {
+	for index := len(guessed) - 1; index >= 0; index-- {
+		v := guessed[index]
+		iface := &interfaceTerm{methods: make(map[string]*funcTerm, len(v.methods))}
+		for name, method := range v.methods {
+			methodType, ok := prune(method).(*funcTerm)
+			if !ok {
+				methodType = &funcTerm{}
+			}
+			iface.methods[name] = methodType
+		}
+
+		// values of the same cycle are seen as any inside the interface
+		var cyclic []*typeVar
+		freeVars(iface, func(current *typeVar) {
+			current.bound = opaqueTerm{}
+			cyclic = append(cyclic, current)
+		})
+		named := i.guessedInterface(iface)
+		for _, current := range cyclic {
+			current.bound = nil
+		}
+		v.bound = named
+	}
+}
+
+// named generic interface "guessedN" (shared by identical interfaces of the package)
+func (i *inferer) guessedInterface(iface *interfaceTerm) *namedTerm {
+	var used []*typeParam
+	for _, name := range sortedNames(iface.methods) {
+		collectParams(iface.methods[name], &used)
+	}
+
+	var args []term
+	params := make([]*typeParam, len(used))
+	mapping := make(map[*typeParam]term, len(used))
+	for index, param := range used {
+		name := "T"
+		if len(used) > 1 {
+			name += strconv.Itoa(index)
+		}
+		params[index] = &typeParam{name: name}
+		mapping[param] = params[index]
+		args = append(args, param)
+	}
+
+	underlying := substitute(iface, mapping).(*interfaceTerm)
+	key := guessKey(underlying)
+	decl, ok := i.guessedTypes[key]
+	if !ok {
+		name := ""
+		for index := len(i.guessedTypes); ; index++ {
+			name = "guessed" + strconv.Itoa(index)
+			if _, exists := i.typeDecls[name]; !exists {
+				break
+			}
+		}
+
+		decl = &typeDecl{name: name, params: params, underlying: underlying, generalized: true}
+		i.typeDecls[name] = decl
+		i.guessedTypes[key] = decl
+		i.file.guessedDecls = append(i.file.guessedDecls, decl)
+	}
+	return &namedTerm{name: decl.name, args: args, decl: decl}
+}
+
+// textual signature of the methods
+func guessKey(iface *interfaceTerm) string {
+	var builder strings.Builder
+	for _, name := range sortedNames(iface.methods) {
+		builder.WriteString(name)
+		builder.WriteString(iface.methods[name].String())
+		builder.WriteByte(';')
+	}
+	return builder.String()
+}
+
+// add each parameter once, keeping the order of appearance
+func collectParams(t term, params *[]*typeParam) {
+	switch casted := prune(t).(type) {
+	case *typeParam:
+		if !slices.Contains(*params, casted) {
+			*params = append(*params, casted)
+		}
+	case *namedTerm:
+		for _, arg := range casted.args {
+			collectParams(arg, params)
+		}
+	case *pointerTerm:
+		collectParams(casted.elem, params)
+	case *sliceTerm:
+		collectParams(casted.elem, params)
+	case *arrayTerm:
+		collectParams(casted.elem, params)
+	case *mapTerm:
+		collectParams(casted.key, params)
+		collectParams(casted.value, params)
+	case *chanTerm:
+		collectParams(casted.elem, params)
+	case *funcTerm:
+		for _, param := range casted.params {
+			collectParams(param, params)
+		}
+		for _, result := range casted.results {
+			collectParams(result, params)
+		}
+	case *tupleTerm:
+		for _, elem := range casted.elems {
+			collectParams(elem, params)
+		}
+	case *structTerm:
+		for _, field := range casted.fields {
+			collectParams(field.typ, params)
+		}
+	case *interfaceTerm:
+		for _, name := range sortedNames(casted.methods) {
+			collectParams(casted.methods[name], params)
+		}
+	}
+}
+
+func sortedNames[T any](m map[string]T) []string {
+	res := make([]string, 0, len(m))
+	for name := range m {
+		res = append(res, name)
+	}
+	slices.Sort(res)
+	return res
+}
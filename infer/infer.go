@@ -13,9 +13,257 @@
 
 package infer
 
-import "github.com/dvaumoron/foresee/types"
+import (
+	"slices"
 
+	"github.com/dvaumoron/foresee/builtins/names"
+	"github.com/dvaumoron/foresee/diagnostic"
+	"github.com/dvaumoron/foresee/types"
+)
+
+// state shared by the inference of a package
+type inferer struct {
+	diagnostics *diagnostic.Collector
+	counter     int
+	pending     []constraint
+	created     []*typeVar // variables of the unit group being inferred
+	nils        []nilUse   // nil values of the unit group being inferred
+
+	file        *fileState // source of the unit being inferred
+	packageName string
+	typeDecls   map[string]*typeDecl
+	funcs       map[string]*funcDecl
+	globals     map[string]term // package level var and const
+
+	rewrites     []func()             // applied once every type is known
+	guessedTypes map[string]*typeDecl // synthesized interfaces by method set
+}
+
+// a nil value needs a type which accepts it
+type nilUse struct {
+	value *typeVar
+	span  types.Span
+}
+
+// state specific to a file of the package
+type fileState struct {
+	list         *types.List
+	imports      map[string]string // path by package name usable as qualifier
+	baseUsed     bool              // a synthesized constraint comes from the base package
+	guessedDecls []*typeDecl       // synthesized interfaces to declare in this file
+}
+
+// Replace the "?" markers and the untyped parameters with the inferred types,
+// the returned error is a diagnostic.List.
 func InferTypes(l *types.List) (*types.List, error) {
-	// TODO
-	return l, nil
+	res, err := InferPackage("", []*types.List{l})
+	return res[0], err
+}
+
+// Same as InferTypes with all the files of a package (declarations are shared between files),
+// the returned lists keep the order of files. When path is not empty, the exported declarations
+// are registered and usable by the packages importing path (which must be inferred afterward).
+func InferPackage(path string, files []*types.List) ([]*types.List, error) {
+	var collector diagnostic.Collector
+	i := &inferer{
+		diagnostics:  &collector,
+		typeDecls:    map[string]*typeDecl{},
+		funcs:        map[string]*funcDecl{},
+		globals:      map[string]term{},
+		guessedTypes: map[string]*typeDecl{},
+	}
+
+	states := make([]*fileState, len(files))
+	for index, l := range files {
+		states[index] = &fileState{list: l, imports: map[string]string{}}
+	}
+
+	units := i.collect(states)
+	for _, group := range orderUnits(units) {
+		i.inferGroup(group)
+	}
+
+	if err := collector.Diagnostics().Err(); err != nil {
+		return files, err
+	}
+
+	for _, rewrite := range i.rewrites {
+		rewrite()
+	}
+	if path != "" {
+		fcPackages.store(path, i)
+	}
+
+	res := make([]*types.List, len(states))
+	for index, file := range states {
+		res[index] = file.insertForms()
+	}
+	return res, nil
+}
+
+// infer a group of mutually dependent units
+func (i *inferer) inferGroup(group []unit) {
+	i.created, i.nils = nil, nil
+	for _, u := range group {
+		u.declare(i)
+	}
+	for _, u := range group {
+		u.check(i)
+	}
+	i.solve()
+	for _, u := range group {
+		u.generalize(i)
+	}
+	i.checkNils()
+
+	// remaining variables only concern local values
+	for _, v := range i.created {
+		if v.bound != nil {
+			continue
+		}
+		if defaultType := defaultLiteralType(v.literal); defaultType != nil {
+			v.bound = defaultType
+		} else {
+			v.bound = opaqueTerm{}
+		}
+	}
+}
+
+// add the base import and the synthesized interfaces after the package and import forms
+func (f *fileState) insertForms() *types.List {
+	var forms []types.Object
+	if _, ok := f.imports["base"]; !ok && f.baseUsed {
+		forms = append(forms, types.NewList(types.Identifier(names.Import), types.String(basePath)))
+	}
+	for _, decl := range f.guessedDecls {
+		forms = append(forms, renderInterfaceDecl(decl))
+	}
+	if len(forms) == 0 {
+		return f.list
+	}
+
+	l := f.list
+	res := types.NewList().SetSpan(l.Span())
+	for index := 0; index < l.Size(); index++ {
+		elem := l.LoadInt(index)
+		if index != 0 && len(forms) != 0 && !isHeaderForm(elem) {
+			res.AddAll(slices.Values(forms))
+			forms = nil
+		}
+		res.AddWithSpan(elem, l.SpanInt(index))
+	}
+	return res.AddAll(slices.Values(forms))
+}
+
+func isHeaderForm(object types.Object) bool {
+	form, ok := object.(*types.List)
+	if !ok {
+		return false
+	}
+	header, _ := form.LoadInt(0).(types.Identifier)
+	return header == names.Package || header == names.Import
+}
+
+func (i *inferer) fresh() *typeVar {
+	return i.freshLiteral(noLiteral)
+}
+
+func (i *inferer) freshLiteral(literal literalKind) *typeVar {
+	i.counter++
+	v := &typeVar{id: i.counter, literal: literal}
+	i.created = append(i.created, v)
+	return v
+}
+
+func (i *inferer) freshNil(span types.Span) *typeVar {
+	v := i.freshLiteral(nilLiteral)
+	i.nils = append(i.nils, nilUse{value: v, span: span})
+	return v
+}
+
+// a nil generalized as a type parameter is invalid (nil is not a T),
+// the one left only in local values becomes any
+func (i *inferer) checkNils() {
+	for _, use := range i.nils {
+		if _, ok := prune(use.value).(*typeParam); ok {
+			i.report(use.span, errNilType)
+		}
+	}
+}
+
+func (i *inferer) onEnd(rewrite func()) {
+	i.rewrites = append(i.rewrites, rewrite)
+}
+
+// lexical scope of a function body
+type scope struct {
+	parent *scope
+	values map[string]term
+	types  map[string]term // type parameters
+	fn     *funcContext
+}
+
+// state of the function (or lambda) being checked
+type funcContext struct {
+	results []term
+	guessed bool         // results come from a "?" marker
+	valued  bool         // a return with values has been met
+	named   bool         // results are named (naked return allowed)
+	tries   []types.Span // try met with "?" results, checked at the end of the body
+}
+
+// a "?" result bound to several values stand for all the results
+func (fn *funcContext) expandResults(sig *funcTerm) {
+	if fn.guessed && len(sig.results) == 1 {
+		if tuple, ok := prune(sig.results[0]).(*tupleTerm); ok {
+			sig.results = tuple.elems
+		}
+	}
+}
+
+// the last result of a function using try is an error,
+// with "?" the results are known only at the end of the body
+func (i *inferer) checkTries(fn *funcContext) {
+	if len(fn.tries) == 0 || len(fn.results) == 0 {
+		return
+	}
+
+	last := fn.results[len(fn.results)-1]
+	for _, span := range fn.tries {
+		i.assign(last, builtinType("error"), span)
+	}
+}
+
+func newScope(parent *scope) *scope {
+	s := &scope{parent: parent, values: map[string]term{}, types: map[string]term{}}
+	if parent != nil {
+		s.fn = parent.fn
+	}
+	return s
+}
+
+func (s *scope) declare(name string, t term) {
+	if name != "_" {
+		s.values[name] = t
+	}
+}
+
+// No panic with nil receiver
+func (s *scope) lookupValue(name string) (term, bool) {
+	for current := s; current != nil; current = current.parent {
+		if t, ok := current.values[name]; ok {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// No panic with nil receiver
+func (s *scope) lookupType(name string) (term, bool) {
+	for current := s; current != nil; current = current.parent {
+		if t, ok := current.types[name]; ok {
+			return t, true
+		}
+	}
+	return nil, false
 }
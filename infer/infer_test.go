@@ -0,0 +1,431 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package infer_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dvaumoron/foresee/builtins/debug"
+	"github.com/dvaumoron/foresee/infer"
+	"github.com/dvaumoron/foresee/parser"
+	"github.com/dvaumoron/foresee/types"
+)
+
+func parseFile(t *testing.T, filePath string) *types.List {
+	t.Helper()
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	l, err := parser.New().Parse(filePath, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// display of each top level node
+func render(l *types.List) []string {
+	var lines []string
+	for node := range l.Iter() {
+		var buffer strings.Builder
+		node.Eval(debug.DebugEnvironment{}).Render(&buffer)
+		lines = append(lines, buffer.String())
+	}
+	return lines
+}
+
+func checkRender(t *testing.T, l *types.List, expected []string) {
+	t.Helper()
+
+	lines := render(l)
+	if len(lines) != len(expected) {
+		t.Fatalf("got %d nodes, want %d : %q", len(lines), len(expected), lines)
+	}
+	for index, line := range lines {
+		if line != expected[index] {
+			t.Errorf("node %d : got %s, want %s", index, line, expected[index])
+		}
+	}
+}
+
+// the annotated file is the expected inference of the example
+func TestInferExample(t *testing.T) {
+	res, err := infer.InferTypes(parseFile(t, "../examples/test.fc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRender(t, res, render(parseFile(t, "../examples/testdata/test.annotated.fc")))
+}
+
+func inferSource(t *testing.T, source string) (*types.List, error) {
+	t.Helper()
+
+	l, err := parser.New().Parse("test.fc", strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return infer.InferTypes(l)
+}
+
+func TestFieldAssignment(t *testing.T) {
+	res, err := inferSource(t, `package p
+
+type Pt struct
+    X ?
+
+func Move(p:*Pt)
+    = p.X 1
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRender(t, res, []string{
+		"file",
+		"(package p)",
+		"(type (gen Pt (list (list T any))) struct (X T))",
+		"(func Move ((list p (* (gen Pt (list int))))) (= (get p X) 1))",
+	})
+}
+
+func TestTryInferredResults(t *testing.T) {
+	res, err := inferSource(t, `package p
+
+func check(s:string) error
+    return nil
+
+func Parse(s:string) ?
+    try (check s)
+    return 1 nil
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRender(t, res, []string{
+		"file",
+		"(package p)",
+		"(func check ((list s string)) error (return nil))",
+		"(func Parse ((list s string)) (list int error) (try (check s)) (return 1 nil))",
+	})
+}
+
+func TestUndefined(t *testing.T) {
+	_, err := inferSource(t, `package p
+
+func F() ?
+    return (unknown 1)
+`)
+	if err == nil || !strings.Contains(err.Error(), "undefined") {
+		t.Errorf("wait an undefined error, got %v", err)
+	}
+}
+
+func TestGoDefer(t *testing.T) {
+	_, err := inferSource(t, `package p
+
+import "fmt"
+
+func F()
+    defer (fmt.Println "done")
+    go (lambda ()
+        fmt.Println "bg")
+`)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInterfaceAssignability(t *testing.T) {
+	for _, source := range []string{`package p
+
+type S interface
+    M() int
+
+type T struct
+    X int
+
+var s:S (T X:1)
+`, `package p
+
+func F() error
+    return 1
+`, `package p
+
+func G() (list int error)
+    return 1 2
+
+func H() any
+    return (G)
+`} {
+		if _, err := inferSource(t, source); err == nil {
+			t.Errorf("wait an error with :\n%s", source)
+		}
+	}
+
+	_, err := inferSource(t, `package p
+
+import "fmt"
+
+type T struct
+    X int
+
+func (t T) String() string
+    return "t"
+
+var s:fmt.Stringer (T X:1)
+var a:any 1
+var e:error nil
+`)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTryResultCount(t *testing.T) {
+	_, err := inferSource(t, `package p
+
+import "strconv"
+
+func F(s:string) error
+    try (strconv.Atoi s)
+    return nil
+`)
+	if err == nil || !strings.Contains(err.Error(), "an error but 2 value(s)") {
+		t.Errorf("wait a result count error, got %v", err)
+	}
+}
+
+func TestIndexGuess(t *testing.T) {
+	res, err := inferSource(t, `package p
+
+func Get(m) ?
+    return ([] m "key")
+
+func At(s) ?
+    return ([] s 0)
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRender(t, res, []string{
+		"file",
+		"(package p)",
+		"(func (gen Get (list (list T any))) ((list m (map string T))) T (return ([] m \"key\")))",
+		"(func (gen At (list (list T any))) ((list s (slice T))) T (return ([] s 0)))",
+	})
+
+	_, err = inferSource(t, `package p
+
+func Bad(s:[]int) ?
+    return ([] s "x")
+`)
+	if err == nil || !strings.Contains(err.Error(), "integer index") {
+		t.Errorf("wait an index error, got %v", err)
+	}
+}
+
+func TestNilWithoutType(t *testing.T) {
+	_, err := inferSource(t, `package p
+
+func Zero () ?
+    return nil
+`)
+	if err == nil || !strings.Contains(err.Error(), "test.fc:4:12 : error : use of nil") {
+		t.Errorf("wait a nil error, got %v", err)
+	}
+
+	res, err := inferSource(t, `package p
+
+func F () ?
+    := f (lambda (x:int) ?
+        return x nil)
+    := (y _) (f 1)
+    return y
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRender(t, res, []string{
+		"file", "(package p)",
+		"(func F nil int (:= f (lambda ((list x int)) (list int any) (return x nil))) (:= (y _) (f 1)) (return y))",
+	})
+}
+
+func TestExplicitInstantiation(t *testing.T) {
+	res, err := inferSource(t, `package p
+
+func Pair[T:any U:any] (x:T) (list T []U)
+    return x nil
+
+func F ()
+    := (x y) (Pair 3)
+    append y "a"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRender(t, res, []string{
+		"file", "(package p)",
+		"(func (gen Pair (list (list T any) (list U any))) ((list x T)) (list T (slice U)) (return x nil))",
+		"(func F nil (:= (x y) ((inst Pair (list int string)) 3)) (append y \"a\"))",
+	})
+}
+
+func TestUndefinedGoMember(t *testing.T) {
+	_, err := inferSource(t, `package p
+
+import "fmt"
+
+func F ()
+    fmt.Printlnx "a"
+    fmt.newPrinter
+`)
+	if err == nil {
+		t.Fatal("wait errors for the unknown members")
+	}
+	for _, message := range []string{"test.fc:6:9 : error : undefined : fmt.Printlnx", "test.fc:7:9 : error : undefined : fmt.newPrinter"} {
+		if !strings.Contains(err.Error(), message) {
+			t.Errorf("wait %q, got %v", message, err)
+		}
+	}
+}
+
+func TestUnknownPackageMember(t *testing.T) {
+	l, err := parser.New().Parse("u.fc", strings.NewReader(`package u
+
+type Pt struct
+    X int
+
+func hidden () int
+    return 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = infer.InferPackage("example.com/m/u", []*types.List{l}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = inferSource(t, `package p
+
+import "example.com/m/u"
+
+func F ()
+    u.hidden
+    u.Nope 1
+    var q:u.Other
+`)
+	if err == nil {
+		t.Fatal("wait errors for the unknown members")
+	}
+	for _, message := range []string{
+		"test.fc:6:7 : error : not exported : u.hidden",
+		"test.fc:7:7 : error : undefined : u.Nope",
+		"test.fc:8:13 : error : undefined : u.Other",
+	} {
+		if !strings.Contains(err.Error(), message) {
+			t.Errorf("wait %q, got %v", message, err)
+		}
+	}
+}
+
+func TestReplaceImports(t *testing.T) {
+	l, err := parser.New().Parse("test.fc", strings.NewReader(`package p
+
+import "example.com/m/u"
+import "strings"
+
+var x:(get "example.com/m/u" Pt)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	infer.ReplaceImports(l, map[string]string{"example.com/m/u": "example.com/m/gen/u"})
+	checkRender(t, l, []string{
+		"file", "(package p)",
+		"(import (u \"example.com/m/gen/u\"))",
+		"(import \"strings\")",
+		"(var (list x (get \"example.com/m/gen/u\" Pt)))",
+	})
+}
+
+// a package inferred before the reset is unknown afterward
+func TestResetPackages(t *testing.T) {
+	l, err := parser.New().Parse("v.fc", strings.NewReader(`package v
+
+func Get () int
+    return 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = infer.InferPackage("example.com/m/v", []*types.List{l}); err != nil {
+		t.Fatal(err)
+	}
+
+	source := `package p
+
+import "example.com/m/v"
+
+var x (v.Get)
+`
+	if _, err = inferSource(t, source); err != nil {
+		t.Fatal(err)
+	}
+
+	infer.ResetPackages()
+	if _, err = inferSource(t, source); err == nil {
+		t.Error("wait an import error after the reset")
+	}
+}
+
+func TestNamedResults(t *testing.T) {
+	res, err := inferSource(t, `package p
+
+func Div(a:int b:int) (list q:int err:error)
+    = q (/ a b)
+    return
+
+func Pair (x:int) ?
+    return x "a"
+
+func Naked (x:int) ?
+    return
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRender(t, res, []string{
+		"file", "(package p)",
+		"(func Div ((list a int) (list b int)) (list (list q int) (list err error)) (= q (/ a b)) (return))",
+		"(func Pair ((list x int)) (list int string) (return x \"a\"))",
+		"(func Naked ((list x int)) nil (return))",
+	})
+
+	// a naked return needs named results
+	_, err = inferSource(t, `package p
+
+func F () int
+    return
+`)
+	if err == nil {
+		t.Error("wait an error for the naked return without named results")
+	}
+}
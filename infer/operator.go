@@ -0,0 +1,285 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package infer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dvaumoron/foresee/builtins/names"
+	"github.com/dvaumoron/foresee/types"
+)
+
+const basePath = "github.com/dvaumoron/foresee/base"
+
+// operator classes applied to a value
+const (
+	addOp     opMask = 1 << iota // +
+	arithOp                      // - * / ++ --
+	integerOp                    // % & | ^ &^ << >>
+	orderOp                      // < <= > >=
+	equalOp                      // == !=
+	logicOp                      // && || !
+	recvOp                       // <- ch
+	sendOp                       // ch <- value
+
+	channelOps = recvOp | sendOp
+)
+
+// set of basic kinds (one bit per basicKind)
+const (
+	boolKinds    kindSet = 1 << boolBasic
+	stringKinds  kindSet = 1 << stringBasic
+	intKinds     kindSet = 1 << intBasic
+	floatKinds   kindSet = 1 << floatBasic
+	complexKinds kindSet = 1 << complexBasic
+
+	realKinds    = intKinds | floatKinds
+	numericKinds = realKinds | complexKinds
+	allKinds     = numericKinds | boolKinds | stringKinds
+)
+
+var (
+	errNotChannel    = errors.New("can not send or receive with")
+	errOperator      = errors.New("operator not defined on")
+	errOperatorMixed = errors.New("no type supports all the operators applied to value of type")
+)
+
+type opMask uint16
+
+type kindSet uint8
+
+var operatorKinds = [...]struct {
+	op    opMask
+	kinds kindSet
+}{
+	{op: addOp, kinds: numericKinds | stringKinds},
+	{op: arithOp, kinds: numericKinds},
+	{op: integerOp, kinds: intKinds},
+	{op: orderOp, kinds: realKinds | stringKinds},
+	{op: logicOp, kinds: boolKinds},
+}
+
+var operatorMasks = map[string]opMask{
+	names.Plus: addOp, names.AddAssign: addOp,
+	names.Minus: arithOp, string(names.StarId): arithOp, names.Slash: arithOp,
+	names.SubAssign: arithOp, names.MultAssign: arithOp, names.DivAssign: arithOp,
+	names.Increment: arithOp, names.Decrement: arithOp,
+	names.Percent: integerOp, string(names.AmpersandId): integerOp, names.Pipe: integerOp, names.Caret: integerOp,
+	names.AndNot: integerOp, names.LShift: integerOp, names.RShift: integerOp,
+	names.ModAssign: integerOp, names.AndAssign: integerOp, names.OrAssign: integerOp, names.XorAssign: integerOp,
+	names.AndNotAssign: integerOp, names.LShiftAssign: integerOp, names.RShiftAssign: integerOp,
+	names.Greater: orderOp, names.GreaterEqual: orderOp, names.Lesser: orderOp, names.LesserEqual: orderOp,
+	names.Equal: equalOp, names.NotEqual: equalOp,
+	names.And: logicOp, names.Or: logicOp, string(names.NotId): logicOp,
+}
+
+// basic kinds supporting every operator class of ops
+func allowedKinds(ops opMask) kindSet {
+	res := allKinds
+	for _, operator := range operatorKinds {
+		if ops&operator.op != 0 {
+			res &= operator.kinds
+		}
+	}
+	return res
+}
+
+// true when no type can support all the operator classes of ops
+func conflicting(ops opMask) bool {
+	if ops&channelOps != 0 {
+		return ops&^(channelOps|equalOp) != 0
+	}
+	return allowedKinds(ops) == 0
+}
+
+// true when a value of type t supports the operator classes of ops
+func opsCompatible(ops opMask, t term) bool {
+	if ops == 0 {
+		return true
+	}
+
+	switch casted := underlying(t).(type) {
+	case opaqueTerm:
+		return true
+	case *typeParam:
+		if casted.ops == 0 {
+			return casted.constraint != nil // explicit constraint, trust the user
+		}
+		return casted.ops&channelOps&ops == ops&channelOps && allowedKinds(casted.ops)&^allowedKinds(ops) == 0
+	case *namedTerm:
+		kind, known := basicKinds[canonicalName(casted.name)]
+		switch {
+		case casted.path != "" || !known:
+			return true // external type, unknown definition
+		case kind == otherBasic:
+			return ops&^equalOp == 0
+		}
+		return ops&channelOps == 0 && allowedKinds(ops)&(1<<kind) != 0
+	case *chanTerm:
+		switch casted.dir {
+		case recvDir:
+			return ops&^(recvOp|equalOp) == 0
+		case sendDir:
+			return ops&^(sendOp|equalOp) == 0
+		}
+		return ops&^(channelOps|equalOp) == 0
+	}
+	return ops&^equalOp == 0
+}
+
+// tightest constraint of base (or builtin) allowing the operator classes of ops
+func (i *inferer) constraintOf(ops opMask, elem term) term {
+	name := ""
+	switch ops & channelOps {
+	case recvOp:
+		name = "Receiver"
+	case sendOp:
+		name = "Sender"
+	case channelOps:
+		name = "Channel"
+	}
+	if name != "" {
+		i.file.baseUsed = true
+		return &namedTerm{path: "base", name: name, args: []term{elem}}
+	}
+
+	switch allowedKinds(ops) {
+	case intKinds:
+		name = "Integer"
+	case realKinds:
+		name = "Real"
+	case numericKinds:
+		name = "Numeric"
+	case numericKinds | stringKinds:
+		name = "Addable"
+	case realKinds | stringKinds:
+		name = "Ordered"
+	case boolKinds:
+		name = "Boolean"
+	default:
+		if ops&equalOp != 0 {
+			return builtinType("comparable")
+		}
+		return nil
+	}
+	i.file.baseUsed = true
+	return &namedTerm{path: "base", name: name}
+}
+
+// record the operator classes of ops applied to a value of type t
+func (i *inferer) require(t term, ops opMask, span types.Span) {
+	switch casted := prune(t).(type) {
+	case *typeVar:
+		if merged := casted.ops | ops; conflicting(merged) {
+			i.report(span, fmt.Errorf("%w %v", errOperatorMixed, casted))
+		} else {
+			casted.ops = merged
+		}
+	default:
+		if !opsCompatible(ops, casted) {
+			i.report(span, fmt.Errorf("%w %v", errOperator, casted))
+		}
+	}
+}
+
+// element type of a channel used with "<-" (op is recvOp or sendOp)
+func (i *inferer) channelElem(channel term, op opMask, span types.Span) term {
+	switch casted := underlying(channel).(type) {
+	case *typeVar:
+		// stay polymorphic, generalized with a channel constraint
+		i.require(casted, op, span)
+		if casted.elem == nil {
+			casted.elem = i.fresh()
+		}
+		return casted.elem
+	case *typeParam:
+		if casted.elem != nil && opsCompatible(op, casted) {
+			return casted.elem
+		}
+		if casted.constraint != nil {
+			return opaqueTerm{}
+		}
+	case *chanTerm:
+		if opsCompatible(op, casted) {
+			return casted.elem
+		}
+	case *namedTerm:
+		if isExternal(casted) {
+			return opaqueTerm{}
+		}
+	case opaqueTerm:
+		return opaqueTerm{}
+	}
+
+	i.report(span, fmt.Errorf("%w %v", errNotChannel, channel))
+	return opaqueTerm{}
+}
+
+// merge the operator requirements of v into other (when v is bound to other)
+func (i *inferer) mergeOps(v *typeVar, other *typeVar) error {
+	merged := v.ops | other.ops
+	if conflicting(merged) {
+		return fmt.Errorf("%w %v", errOperatorMixed, other)
+	}
+
+	other.ops = merged
+	switch {
+	case v.elem == nil:
+	case other.elem == nil:
+		other.elem = v.elem
+	default:
+		return i.unify(v.elem, other.elem)
+	}
+	return nil
+}
+
+// check the operator requirements of v against t (when v is bound to t)
+func (i *inferer) checkOps(v *typeVar, t term) error {
+	if !opsCompatible(v.ops, t) {
+		return fmt.Errorf("%w %v", errOperator, t)
+	}
+	if v.elem == nil {
+		return nil
+	}
+
+	switch casted := underlying(t).(type) {
+	case *chanTerm:
+		return i.unify(v.elem, casted.elem)
+	case *typeParam:
+		if casted.elem != nil {
+			return i.unify(v.elem, casted.elem)
+		}
+	}
+	i.bindOpaque(v.elem)
+	return nil
+}
+
+// create a variable for each parameter, keeping their operator requirements
+func (i *inferer) freshArgs(params []*typeParam) []term {
+	args := make([]term, len(params))
+	for index, param := range params {
+		v := i.fresh()
+		v.ops = param.ops
+		args[index] = v
+	}
+
+	mapping := mappingOf(params, args)
+	for index, param := range params {
+		if param.elem != nil {
+			args[index].(*typeVar).elem = substitute(param.elem, mapping)
+		}
+	}
+	return args
+}
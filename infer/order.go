@@ -0,0 +1,106 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package infer
+
+import "slices"
+
+// state of the Tarjan algorithm
+type orderState struct {
+	edges   [][]int
+	indexes []int // 0 when not visited
+	lowLink []int
+	onStack []bool
+	stack   []int
+	counter int
+	groups  [][]int
+}
+
+// split units in groups of mutually dependent units,
+// a group come after the groups it depends on
+func orderUnits(units []unit) [][]unit {
+	definedBy := map[string][]int{}
+	for index, u := range units {
+		u.defines(func(name string) {
+			definedBy[name] = append(definedBy[name], index)
+		})
+	}
+
+	state := &orderState{
+		edges:   make([][]int, len(units)),
+		indexes: make([]int, len(units)),
+		lowLink: make([]int, len(units)),
+		onStack: make([]bool, len(units)),
+	}
+	for index, u := range units {
+		seen := map[int]struct{}{index: {}}
+		u.references(func(name string) {
+			for _, target := range definedBy[name] {
+				if _, ok := seen[target]; !ok {
+					seen[target] = struct{}{}
+					state.edges[index] = append(state.edges[index], target)
+				}
+			}
+		})
+	}
+
+	for index := range units {
+		if state.indexes[index] == 0 {
+			state.visit(index)
+		}
+	}
+
+	res := make([][]unit, len(state.groups))
+	for index, group := range state.groups {
+		for _, unitIndex := range group {
+			res[index] = append(res[index], units[unitIndex])
+		}
+	}
+	return res
+}
+
+func (s *orderState) visit(current int) {
+	s.counter++
+	s.indexes[current] = s.counter
+	s.lowLink[current] = s.counter
+	s.stack = append(s.stack, current)
+	s.onStack[current] = true
+
+	for _, target := range s.edges[current] {
+		if s.indexes[target] == 0 {
+			s.visit(target)
+			s.lowLink[current] = min(s.lowLink[current], s.lowLink[target])
+		} else if s.onStack[target] {
+			s.lowLink[current] = min(s.lowLink[current], s.indexes[target])
+		}
+	}
+
+	if s.lowLink[current] != s.indexes[current] {
+		return
+	}
+
+	// current is the root of a group
+	var group []int
+	for {
+		last := len(s.stack) - 1
+		member := s.stack[last]
+		s.stack = s.stack[:last]
+		s.onStack[member] = false
+		group = append(group, member)
+		if member == current {
+			break
+		}
+	}
+	slices.Sort(group) // keep the declaration order inside a group
+	s.groups = append(s.groups, group)
+}
@@ -0,0 +1,190 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package infer
+
+import (
+	"strings"
+
+	"github.com/dvaumoron/foresee/builtins/names"
+	"github.com/dvaumoron/foresee/types"
+)
+
+const anyId types.Identifier = "any"
+
+// convert a term to the syntax understood by the compile package
+func render(t term) types.Object {
+	switch casted := prune(t).(type) {
+	case *typeVar:
+		if defaultType := defaultLiteralType(casted.literal); defaultType != nil {
+			return render(defaultType)
+		}
+	case *typeParam:
+		return types.Identifier(casted.name)
+	case *namedTerm:
+		var nameObject types.Object = types.Identifier(casted.name)
+		switch {
+		case casted.goType != nil:
+			nameObject = types.NewList(names.GetId, types.String(casted.path), nameObject)
+		case casted.decl != nil && casted.decl.path != "":
+			nameObject = types.NewList(names.GetId, types.String(casted.decl.path), nameObject)
+		case casted.path != "":
+			nameObject = types.NewList(names.GetId, types.Identifier(casted.path), nameObject)
+		}
+		if args := casted.arguments(); len(args) != 0 {
+			return types.NewList(names.GenId, nameObject, renderList(args))
+		}
+		return nameObject
+	case *pointerTerm:
+		return types.NewList(names.StarId, render(casted.elem))
+	case *sliceTerm:
+		return types.NewList(names.SliceId, render(casted.elem))
+	case *arrayTerm:
+		if casted.size < 0 {
+			return types.NewList(names.SliceId, names.EllipsisId, render(casted.elem))
+		}
+		return types.NewList(names.SliceId, types.Integer(casted.size), render(casted.elem))
+	case *mapTerm:
+		return types.NewList(names.MapId, render(casted.key), render(casted.value))
+	case *chanTerm:
+		switch casted.dir {
+		case recvDir:
+			return types.NewList(names.ArrowChanId, render(casted.elem))
+		case sendDir:
+			return types.NewList(names.ChanArrowId, render(casted.elem))
+		}
+		return types.NewList(names.ChanId, render(casted.elem))
+	case *funcTerm:
+		params := renderList(casted.params)
+		if casted.variadic {
+			last := params.Size() - 1
+			elem := casted.params[last-1].(*sliceTerm).elem
+			params.Store(types.Integer(last), types.NewList(names.EllipsisId, render(elem)))
+		}
+		if len(casted.results) == 0 {
+			return types.NewList(names.FuncId, params)
+		}
+		return types.NewList(names.FuncId, params, renderList(casted.results))
+	case *structTerm:
+		res := types.NewList(names.StructId)
+		for _, field := range casted.fields {
+			if field.embedded {
+				res.Add(render(field.typ))
+			} else {
+				res.Add(types.NewList(names.ListId, types.Identifier(field.name), render(field.typ)))
+			}
+		}
+		return res
+	case *interfaceTerm:
+		if len(casted.methods) == 0 && len(casted.embeds) == 0 {
+			break
+		}
+
+		res := types.NewList(names.InterfaceId)
+		for _, name := range sortedNames(casted.methods) {
+			res.Add(types.NewList(names.ListId, types.Identifier(name), render(casted.methods[name])))
+		}
+		for _, embed := range casted.embeds {
+			res.Add(render(embed))
+		}
+		return res
+	}
+	return anyId // opaque, empty interface or not representable
+}
+
+// "(list elems...)"
+func renderList(terms []term) *types.List {
+	res := types.NewList(names.ListId)
+	for _, t := range terms {
+		res.Add(render(t))
+	}
+	return res
+}
+
+// "(gen name (list (list T constraint)...))"
+func renderGenericName(name string, params []*typeParam) types.Object {
+	if len(params) == 0 {
+		return types.Identifier(name)
+	}
+
+	genDefs := types.NewList(names.ListId)
+	for _, param := range params {
+		var constraint types.Object = anyId
+		if param.constraint != nil {
+			constraint = render(param.constraint)
+		}
+		genDefs.Add(types.NewList(names.ListId, types.Identifier(param.name), constraint))
+	}
+	return types.NewList(names.GenId, types.Identifier(name), genDefs)
+}
+
+// "(inst callee (list type...))"
+func renderInstantiation(callee types.Object, args []term) *types.List {
+	typeList := types.NewList(names.ListId)
+	for _, arg := range args {
+		typeList.Add(render(arg))
+	}
+	return types.NewList(names.InstId, callee, typeList)
+}
+
+// "(type (gen name (list (list T any)...)) interface (Method (param types) result)...)"
+func renderInterfaceDecl(decl *typeDecl) *types.List {
+	res := types.NewList(types.Identifier(names.Type), renderGenericName(decl.name, decl.params), names.InterfaceId)
+	iface, _ := decl.underlying.(*interfaceTerm)
+	for _, name := range sortedNames(iface.methods) {
+		method := iface.methods[name]
+		paramTypes := types.NewList()
+		for index, param := range method.params {
+			if method.variadic && index == len(method.params)-1 {
+				paramTypes.Add(types.NewList(names.EllipsisId, render(prune(param).(*sliceTerm).elem)))
+			} else {
+				paramTypes.Add(render(param))
+			}
+		}
+
+		desc := types.NewList(types.Identifier(name), paramTypes)
+		switch len(method.results) {
+		case 0:
+		case 1:
+			desc.Add(render(method.results[0]))
+		default:
+			desc.Add(renderList(method.results))
+		}
+		res.Add(desc)
+	}
+	return res
+}
+
+// textual form of object for error messages
+func renderString(object types.Object) string {
+	var builder strings.Builder
+	writeObject(&builder, object)
+	return builder.String()
+}
+
+func writeObject(builder *strings.Builder, object types.Object) {
+	list, ok := object.(*types.List)
+	if !ok {
+		object.Render(builder)
+		return
+	}
+
+	builder.WriteByte('(')
+	for index := 0; index < list.Size(); index++ {
+		if index != 0 {
+			builder.WriteByte(' ')
+		}
+		writeObject(builder, list.LoadInt(index))
+	}
+	builder.WriteByte(')')
+}
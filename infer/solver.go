@@ -0,0 +1,618 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package infer
+
+import (
+	"errors"
+	"fmt"
+	gotypes "go/types"
+	"maps"
+
+	"github.com/dvaumoron/foresee/types"
+)
+
+var (
+	errArgumentCount = errors.New("wrong number of arguments")
+	errIndexType     = errors.New("wait integer index, get")
+	errNotCallable   = errors.New("can not call value of type")
+	errNotIndexable  = errors.New("can not index value of type")
+	errNotRangeable  = errors.New("can not range over value of type")
+	errUnknownField  = errors.New("unknown field or method")
+	errUnresolved    = errors.New("can not infer type")
+)
+
+// A constraint is retried until solved,
+// final is true when nothing else can progress (must solve or report an error).
+type constraint interface {
+	solve(i *inferer, final bool) bool
+}
+
+// dst = src with interface assignability
+type assignConstraint struct {
+	dst  term
+	src  term
+	span types.Span
+}
+
+func (c assignConstraint) solve(i *inferer, final bool) bool {
+	dst := prune(c.dst)
+	if _, ok := dst.(*typeVar); ok && !final {
+		return false
+	}
+
+	if isInterface(dst) {
+		return i.satisfy(dst, c.src, c.span, final)
+	}
+	i.equal(dst, c.src, c.span)
+	return true
+}
+
+// the call of a try without targets gives only the error
+type tryConstraint struct {
+	value term
+	span  types.Span
+}
+
+func (c tryConstraint) solve(i *inferer, final bool) bool {
+	switch casted := prune(c.value).(type) {
+	case *typeVar:
+		if !final {
+			return false
+		}
+	case *tupleTerm:
+		i.report(c.span, fmt.Errorf("%w : 0 variable(s) and an error but %d value(s)", errAssignCount, len(casted.elems)))
+		return true
+	}
+	i.assign(builtinType("error"), c.value, c.span)
+	return true
+}
+
+// result is the type of recv.name (field or method value)
+type fieldConstraint struct {
+	recv   term
+	name   string
+	result term
+	method bool // from a method call
+	span   types.Span
+}
+
+func (c fieldConstraint) solve(i *inferer, final bool) bool {
+	recv := prune(c.recv)
+	if pointer, ok := recv.(*pointerTerm); ok {
+		recv = prune(pointer.elem)
+	}
+
+	switch casted := recv.(type) {
+	case *typeVar:
+		if !final {
+			return false
+		}
+
+		if c.method {
+			// generalized with an interface when it stays unknown
+			i.equal(c.result, i.requireMethod(casted, c.name), c.span)
+			return true
+		}
+		if owner := i.fieldOwner(c.name); owner != nil {
+			// only one local type could match
+			i.equal(casted, owner.instantiate(i), c.span)
+			return c.solve(i, final)
+		}
+
+		i.report(c.span, fmt.Errorf("%w of value with field %s", errUnresolved, c.name))
+		i.bindOpaque(casted) // report once
+		i.bindOpaque(c.result)
+		return true
+	case opaqueTerm:
+		i.bindOpaque(c.result)
+		return true
+	}
+
+	selected, found := i.selectMember(recv, c.name, 0)
+	if !found {
+		if named, ok := recv.(*namedTerm); ok && isExternal(named) {
+			i.bindOpaque(c.result)
+			return true
+		}
+
+		i.report(c.span, fmt.Errorf("%w %s in %v", errUnknownField, c.name, recv))
+		i.bindOpaque(c.result)
+		return true
+	}
+	i.equal(c.result, selected, c.span)
+	return true
+}
+
+// return the only local struct type with a field name (nil if there is none or several)
+func (i *inferer) fieldOwner(name string) *typeDecl {
+	var owner *typeDecl
+	for _, decl := range i.typeDecls {
+		structType, ok := decl.underlying.(*structTerm)
+		if !ok || structType.fieldIndex(name) < 0 {
+			continue
+		}
+		if owner != nil {
+			return nil
+		}
+		owner = decl
+	}
+	return owner
+}
+
+// result is the type returned by a call of callee with args
+type callConstraint struct {
+	callee term
+	args   []term
+	spread bool // last argument use "..."
+	result term
+	span   types.Span
+}
+
+func (c callConstraint) solve(i *inferer, final bool) bool {
+	switch callee := underlying(c.callee).(type) {
+	case *typeVar:
+		if !final {
+			return false
+		}
+
+		results := []term{c.result}
+		if tuple, ok := prune(c.result).(*tupleTerm); ok {
+			results = tuple.elems
+		}
+		i.equal(callee, &funcTerm{params: c.args, results: results, variadic: c.spread}, c.span)
+	case opaqueTerm:
+		i.bindOpaque(c.result)
+	case *funcTerm:
+		i.matchArguments(callee, c.args, c.spread, c.span)
+		switch len(callee.results) {
+		case 1:
+			i.equal(c.result, callee.results[0], c.span)
+		default:
+			i.equal(c.result, &tupleTerm{elems: callee.results}, c.span)
+		}
+	default:
+		i.report(c.span, fmt.Errorf("%w %v", errNotCallable, callee))
+		i.bindOpaque(c.result)
+	}
+	return true
+}
+
+func (i *inferer) matchArguments(callee *funcTerm, args []term, spread bool, span types.Span) {
+	paramCount := len(callee.params)
+	if len(args) == 1 && paramCount > 1 {
+		// f(g()) with g returning several values
+		if tuple, ok := prune(args[0]).(*tupleTerm); ok {
+			args = tuple.elems
+		}
+	}
+
+	if callee.variadic {
+		if len(args) < paramCount-1 {
+			i.report(span, errArgumentCount)
+			return
+		}
+
+		for index, arg := range args {
+			if index < paramCount-1 {
+				i.assign(callee.params[index], arg, span)
+				continue
+			}
+
+			last := callee.params[paramCount-1]
+			if spread {
+				i.assign(last, arg, span)
+				break
+			}
+			if slice, ok := underlying(last).(*sliceTerm); ok {
+				i.assign(slice.elem, arg, span)
+			}
+		}
+		return
+	}
+
+	if len(args) != paramCount {
+		i.report(span, fmt.Errorf("%w : wait %d, get %d", errArgumentCount, paramCount, len(args)))
+		return
+	}
+	for index, arg := range args {
+		i.assign(callee.params[index], arg, span)
+	}
+}
+
+// result is the type of container[index] (or container[index:...] when slicing)
+type indexConstraint struct {
+	container term
+	index     term
+	result    term
+	slicing   bool
+	span      types.Span
+}
+
+func (c indexConstraint) solve(i *inferer, final bool) bool {
+	container := underlying(c.container)
+	if pointer, ok := container.(*pointerTerm); ok {
+		container = underlying(pointer.elem)
+	}
+
+	switch casted := container.(type) {
+	case *typeVar:
+		if !final {
+			return false
+		}
+
+		// nothing else known, guess a map when the index is not an integer, a slice otherwise
+		if !c.slicing && !isIntegerIndex(c.index) {
+			i.equal(casted, &mapTerm{key: c.index, value: c.result}, c.span)
+			return true
+		}
+		i.equal(casted, &sliceTerm{elem: c.result}, c.span)
+		if c.slicing {
+			i.equal(c.result, casted, c.span)
+		} else {
+			i.requireIntegerIndex(c.index, c.span)
+		}
+		return true
+	case opaqueTerm:
+		i.bindOpaque(c.result)
+		return true
+	case *mapTerm:
+		i.assign(casted.key, c.index, c.span)
+		i.equal(c.result, casted.value, c.span)
+		return true
+	}
+
+	if !c.slicing {
+		i.requireIntegerIndex(c.index, c.span)
+	}
+	switch casted := container.(type) {
+	case *sliceTerm:
+		if c.slicing {
+			i.equal(c.result, c.container, c.span)
+		} else {
+			i.equal(c.result, casted.elem, c.span)
+		}
+	case *arrayTerm:
+		if c.slicing {
+			i.equal(c.result, &sliceTerm{elem: casted.elem}, c.span)
+		} else {
+			i.equal(c.result, casted.elem, c.span)
+		}
+	case *namedTerm:
+		switch {
+		case casted.path != "":
+			i.bindOpaque(c.result)
+		case isBuiltin(casted, "string"):
+			if c.slicing {
+				i.equal(c.result, c.container, c.span)
+			} else {
+				i.equal(c.result, builtinType("byte"), c.span)
+			}
+		default:
+			i.report(c.span, fmt.Errorf("%w %v", errNotIndexable, casted))
+			i.bindOpaque(c.result)
+		}
+	default:
+		i.report(c.span, fmt.Errorf("%w %v", errNotIndexable, casted))
+		i.bindOpaque(c.result)
+	}
+	return true
+}
+
+// an unknown index without constant origin could still be an integer
+func isIntegerIndex(index term) bool {
+	switch casted := prune(index).(type) {
+	case *typeVar:
+		return casted.literal == noLiteral || casted.literal == intLiteral || casted.literal == runeLiteral
+	case *namedTerm:
+		kind, known := basicKinds[canonicalName(casted.name)]
+		return casted.path != "" || (known && kind == intBasic)
+	case *typeParam, opaqueTerm:
+		return true
+	}
+	return false
+}
+
+// slice, array and string indexes are integers
+func (i *inferer) requireIntegerIndex(index term, span types.Span) {
+	if !isIntegerIndex(index) {
+		var desc any = prune(index)
+		if casted, ok := desc.(*typeVar); ok {
+			desc = literalNames[casted.literal]
+		}
+		i.report(span, fmt.Errorf("%w %v", errIndexType, desc))
+		return
+	}
+	if casted, ok := prune(index).(*typeVar); ok {
+		i.require(casted, integerOp, span)
+	}
+}
+
+// key and value are the types produced by "range container"
+type rangeConstraint struct {
+	container term
+	key       term
+	value     term
+	span      types.Span
+}
+
+func (c rangeConstraint) solve(i *inferer, final bool) bool {
+	container := underlying(c.container)
+	if pointer, ok := container.(*pointerTerm); ok {
+		container = underlying(pointer.elem)
+	}
+
+	switch casted := container.(type) {
+	case *typeVar:
+		if !final {
+			return false
+		}
+
+		// nothing else known, guess a slice
+		i.equal(casted, &sliceTerm{elem: c.value}, c.span)
+		i.equal(c.key, builtinType("int"), c.span)
+	case opaqueTerm:
+		i.bindOpaque(c.key)
+		i.bindOpaque(c.value)
+	case *sliceTerm:
+		i.equal(c.key, builtinType("int"), c.span)
+		i.equal(c.value, casted.elem, c.span)
+	case *arrayTerm:
+		i.equal(c.key, builtinType("int"), c.span)
+		i.equal(c.value, casted.elem, c.span)
+	case *mapTerm:
+		i.equal(c.key, casted.key, c.span)
+		i.equal(c.value, casted.value, c.span)
+	case *chanTerm:
+		i.equal(c.key, casted.elem, c.span)
+		i.bindOpaque(c.value)
+	case *funcTerm:
+		// range over function iterator
+		yieldFunc, ok := underlying(casted.params[0]).(*funcTerm)
+		if len(casted.params) != 1 || !ok {
+			i.report(c.span, fmt.Errorf("%w %v", errNotRangeable, casted))
+			break
+		}
+
+		yielded := append(yieldFunc.params, opaqueTerm{}, opaqueTerm{})
+		i.equal(c.key, yielded[0], c.span)
+		i.equal(c.value, yielded[1], c.span)
+	case *namedTerm:
+		switch kind := basicKinds[canonicalName(casted.name)]; {
+		case casted.path != "":
+			i.bindOpaque(c.key)
+			i.bindOpaque(c.value)
+		case kind == stringBasic:
+			i.equal(c.key, builtinType("int"), c.span)
+			i.equal(c.value, builtinType("rune"), c.span)
+		case kind == intBasic:
+			i.equal(c.key, casted, c.span)
+			i.bindOpaque(c.value)
+		default:
+			i.report(c.span, fmt.Errorf("%w %v", errNotRangeable, casted))
+		}
+	default:
+		i.report(c.span, fmt.Errorf("%w %v", errNotRangeable, casted))
+	}
+	return true
+}
+
+func (i *inferer) addConstraint(c constraint) {
+	i.pending = append(i.pending, c)
+}
+
+func (i *inferer) assign(dst term, src term, span types.Span) {
+	i.addConstraint(assignConstraint{dst: dst, src: src, span: span})
+}
+
+// unify and report failure
+func (i *inferer) equal(a term, b term, span types.Span) {
+	if err := i.unify(a, b); err != nil {
+		i.report(span, err)
+	}
+}
+
+func (i *inferer) bindOpaque(t term) {
+	if v, ok := prune(t).(*typeVar); ok {
+		v.bound = opaqueTerm{}
+	}
+}
+
+func (i *inferer) report(span types.Span, err error) {
+	i.diagnostics.AddError(span, err)
+}
+
+// check (and unify when possible) the methods of iface against the ones of src
+func (i *inferer) satisfy(iface term, src term, span types.Span, final bool) bool {
+	concrete := prune(src)
+	switch casted := concrete.(type) {
+	case *typeVar:
+		switch {
+		case casted.literal == nilLiteral:
+			i.equal(casted, iface, span)
+			return true
+		case casted.literal != noLiteral:
+			if !final {
+				return false // the constant could still get a type
+			}
+
+			// an untyped constant is converted to its default type
+			i.equal(casted, defaultLiteralType(casted.literal), span)
+			return i.satisfy(iface, casted, span, final)
+		case final:
+			i.equal(iface, concrete, span)
+		}
+		return final
+	case *tupleTerm:
+		i.report(span, fmt.Errorf("%w : 1 variable but %d value(s)", errAssignCount, len(casted.elems)))
+		return true
+	case opaqueTerm:
+		return true
+	}
+
+	for name, method := range i.interfaceMethods(iface, 0) {
+		selected, found := i.selectMember(concrete, name, 0)
+		switch {
+		case found:
+			i.equal(method, selected, span)
+		case !hasUnknownMembers(concrete):
+			i.report(span, fmt.Errorf("%w : %v does not implement %v (missing method %s)", errMismatch, concrete, iface, name))
+			return true // report once
+		}
+	}
+	return true
+}
+
+// methods required by an interface (including the embedded ones)
+func (i *inferer) interfaceMethods(iface term, depth int) map[string]term {
+	if depth > 10 { // avoid infinite loop with wrong definition
+		return nil
+	}
+
+	switch casted := underlying(iface).(type) {
+	case *interfaceTerm:
+		methods := map[string]term{}
+		for _, embed := range casted.embeds {
+			maps.Copy(methods, i.interfaceMethods(embed, depth+1))
+		}
+		for name, method := range casted.methods {
+			methods[name] = method
+		}
+		return methods
+	case *namedTerm:
+		if isBuiltin(casted, "error") {
+			return map[string]term{"Error": &funcTerm{results: []term{builtinType("string")}}}
+		}
+		if casted.goType == nil {
+			break
+		}
+		if goIface, ok := casted.goType.Underlying().(*gotypes.Interface); ok {
+			methods := map[string]term{}
+			for index := range goIface.NumMethods() {
+				name := goIface.Method(index).Name()
+				methods[name], _ = i.goMember(casted, name)
+			}
+			return methods
+		}
+	}
+	return nil
+}
+
+// the members of the external types and type parameters are not all known
+func hasUnknownMembers(t term) bool {
+	t = prune(t)
+	if pointer, ok := t.(*pointerTerm); ok {
+		t = prune(pointer.elem)
+	}
+
+	switch casted := t.(type) {
+	case opaqueTerm, *typeVar, *typeParam:
+		return true
+	case *namedTerm:
+		return isExternal(casted) && casted.goType == nil
+	}
+	return false
+}
+
+// type with an unknown definition
+func isExternal(n *namedTerm) bool {
+	if n.path != "" {
+		return true
+	}
+
+	_, known := basicKinds[canonicalName(n.name)]
+	return n.decl == nil && !known
+}
+
+// type of the field or method name of t (with promotion through embedded fields)
+func (i *inferer) selectMember(t term, name string, depth int) (term, bool) {
+	if depth > 10 { // avoid infinite loop with wrong definition
+		return nil, false
+	}
+
+	t = prune(t)
+	if pointer, ok := t.(*pointerTerm); ok {
+		t = prune(pointer.elem)
+	}
+	if named, ok := t.(*namedTerm); ok {
+		switch {
+		case named.decl != nil:
+			if method, ok := named.decl.methods[name]; ok && method.sig != nil {
+				return substituteFunc(method.sig, mappingOf(named.decl.params, named.arguments())), true
+			}
+		case named.goType != nil:
+			return i.goMember(named, name)
+		case isBuiltin(named, "error") && name == "Error":
+			return &funcTerm{results: []term{builtinType("string")}}, true
+		}
+	}
+
+	switch casted := underlying(t).(type) {
+	case *structTerm:
+		for _, field := range casted.fields {
+			if field.name == name {
+				return field.typ, true
+			}
+		}
+		for _, field := range casted.fields {
+			if field.embedded {
+				if selected, ok := i.selectMember(field.typ, name, depth+1); ok {
+					return selected, true
+				}
+			}
+		}
+	case *interfaceTerm:
+		if method, ok := casted.methods[name]; ok {
+			return method, true
+		}
+		for _, embed := range casted.embeds {
+			if selected, ok := i.selectMember(embed, name, depth+1); ok {
+				return selected, true
+			}
+		}
+	case *typeParam:
+		if casted.constraint != nil {
+			return i.selectMember(casted.constraint, name, depth+1)
+		}
+	}
+	return nil, false
+}
+
+// solve pending constraints until none remains
+func (i *inferer) solve() {
+	for len(i.pending) != 0 {
+		if i.solveStep(false) {
+			continue
+		}
+
+		// nothing progress, force the oldest constraint
+		forced := i.pending[0]
+		i.pending = i.pending[1:]
+		forced.solve(i, true)
+	}
+}
+
+// return true when a constraint has been solved
+func (i *inferer) solveStep(final bool) bool {
+	progress := false
+	current := i.pending
+	i.pending = nil
+	for _, c := range current {
+		if c.solve(i, final) {
+			progress = true
+		} else {
+			i.pending = append(i.pending, c)
+		}
+	}
+	return progress
+}
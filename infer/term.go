@@ -0,0 +1,378 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package infer
+
+import (
+	gotypes "go/types"
+	"strconv"
+	"strings"
+)
+
+const (
+	noLiteral literalKind = iota
+	intLiteral
+	runeLiteral
+	floatLiteral
+	nilLiteral
+	stringLiteral
+	boolLiteral
+)
+
+const (
+	bothDir chanDir = iota
+	recvDir
+	sendDir
+)
+
+type literalKind uint8
+
+type chanDir uint8
+
+// type expression manipulated by the inference
+type term interface {
+	String() string
+}
+
+// unknown type, solved by binding it
+type typeVar struct {
+	id      int
+	bound   term
+	literal literalKind     // untyped constant origin, give a default type
+	ops     opMask          // operator classes applied to the values
+	elem    term            // element of the channel when used with "<-"
+	methods map[string]term // methods called on the values
+}
+
+func (v *typeVar) String() string {
+	if v.bound != nil {
+		return v.bound.String()
+	}
+	return "?" + strconv.Itoa(v.id)
+}
+
+// generic parameter of a declaration (only equal to itself)
+type typeParam struct {
+	name       string
+	constraint term   // nil means any
+	ops        opMask // operator classes behind a synthesized constraint
+	elem       term   // element of the channel constraint
+}
+
+func (p *typeParam) String() string {
+	return p.name
+}
+
+// builtin, local or external named type,
+// args is nil for a local generic type used inside its own declaration group
+type namedTerm struct {
+	path string // import path (or qualifier when unknown), empty for builtin and local type
+	name string
+	args []term
+	decl *typeDecl // nil for builtin and external type
+
+	goType *gotypes.Named // definition of a type from an imported Go package
+}
+
+func (n *namedTerm) String() string {
+	var builder strings.Builder
+	if n.path != "" {
+		builder.WriteString(n.path)
+		builder.WriteByte('.')
+	}
+	builder.WriteString(n.name)
+	writeTermList(&builder, "[", n.arguments(), "]")
+	return builder.String()
+}
+
+// resolve nil args to the parameters of the declaration
+func (n *namedTerm) arguments() []term {
+	if n.args == nil && n.decl != nil && len(n.decl.params) != 0 {
+		args := make([]term, len(n.decl.params))
+		for index, param := range n.decl.params {
+			args[index] = param
+		}
+		return args
+	}
+	return n.args
+}
+
+type pointerTerm struct {
+	elem term
+}
+
+func (p *pointerTerm) String() string {
+	return "*" + p.elem.String()
+}
+
+type sliceTerm struct {
+	elem term
+}
+
+func (s *sliceTerm) String() string {
+	return "[]" + s.elem.String()
+}
+
+type arrayTerm struct {
+	size int64 // negative when computed from the literal ([...]type)
+	elem term
+}
+
+func (a *arrayTerm) String() string {
+	if a.size < 0 {
+		return "[...]" + a.elem.String()
+	}
+	return "[" + strconv.FormatInt(a.size, 10) + "]" + a.elem.String()
+}
+
+type mapTerm struct {
+	key   term
+	value term
+}
+
+func (m *mapTerm) String() string {
+	return "map[" + m.key.String() + "]" + m.value.String()
+}
+
+type chanTerm struct {
+	dir  chanDir
+	elem term
+}
+
+func (c *chanTerm) String() string {
+	switch c.dir {
+	case recvDir:
+		return "<-chan " + c.elem.String()
+	case sendDir:
+		return "chan<- " + c.elem.String()
+	}
+	return "chan " + c.elem.String()
+}
+
+type funcTerm struct {
+	params   []term
+	results  []term
+	variadic bool // last param is a slice
+}
+
+func (f *funcTerm) String() string {
+	var builder strings.Builder
+	builder.WriteString("func")
+	writeTermList(&builder, "(", f.params, ")")
+	if len(f.params) == 0 {
+		builder.WriteString("()")
+	}
+	switch len(f.results) {
+	case 0:
+	case 1:
+		builder.WriteByte(' ')
+		builder.WriteString(f.results[0].String())
+	default:
+		builder.WriteByte(' ')
+		writeTermList(&builder, "(", f.results, ")")
+	}
+	return builder.String()
+}
+
+// multiple values of a call
+type tupleTerm struct {
+	elems []term
+}
+
+func (t *tupleTerm) String() string {
+	var builder strings.Builder
+	writeTermList(&builder, "(", t.elems, ")")
+	if len(t.elems) == 0 {
+		builder.WriteString("()")
+	}
+	return builder.String()
+}
+
+type fieldTerm struct {
+	name     string
+	typ      term
+	embedded bool
+}
+
+type structTerm struct {
+	fields []fieldTerm
+}
+
+func (s *structTerm) String() string {
+	var builder strings.Builder
+	builder.WriteString("struct{")
+	for index, field := range s.fields {
+		if index != 0 {
+			builder.WriteString("; ")
+		}
+		if !field.embedded {
+			builder.WriteString(field.name)
+			builder.WriteByte(' ')
+		}
+		builder.WriteString(field.typ.String())
+	}
+	builder.WriteByte('}')
+	return builder.String()
+}
+
+type interfaceTerm struct {
+	methods map[string]*funcTerm
+	embeds  []term
+}
+
+func (i *interfaceTerm) String() string {
+	if len(i.methods) == 0 && len(i.embeds) == 0 {
+		return "any"
+	}
+	return "interface{...}"
+}
+
+// type from an unknown source, compatible with everything
+type opaqueTerm struct{}
+
+func (opaqueTerm) String() string {
+	return "unknown"
+}
+
+func writeTermList(builder *strings.Builder, start string, terms []term, end string) {
+	if len(terms) == 0 {
+		return
+	}
+
+	builder.WriteString(start)
+	for index, t := range terms {
+		if index != 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteString(t.String())
+	}
+	builder.WriteString(end)
+}
+
+// follow the binding of variables
+func prune(t term) term {
+	for {
+		v, ok := t.(*typeVar)
+		if !ok || v.bound == nil {
+			return t
+		}
+		t = v.bound
+	}
+}
+
+// copy t replacing parameters with the matching terms
+func substitute(t term, mapping map[*typeParam]term) term {
+	switch casted := prune(t).(type) {
+	case *typeParam:
+		if res, ok := mapping[casted]; ok {
+			return res
+		}
+		return casted
+	case *namedTerm:
+		args := casted.arguments()
+		if len(args) == 0 {
+			return casted
+		}
+		return &namedTerm{path: casted.path, name: casted.name, args: substituteAll(args, mapping), decl: casted.decl, goType: casted.goType}
+	case *pointerTerm:
+		return &pointerTerm{elem: substitute(casted.elem, mapping)}
+	case *sliceTerm:
+		return &sliceTerm{elem: substitute(casted.elem, mapping)}
+	case *arrayTerm:
+		return &arrayTerm{size: casted.size, elem: substitute(casted.elem, mapping)}
+	case *mapTerm:
+		return &mapTerm{key: substitute(casted.key, mapping), value: substitute(casted.value, mapping)}
+	case *chanTerm:
+		return &chanTerm{dir: casted.dir, elem: substitute(casted.elem, mapping)}
+	case *funcTerm:
+		return substituteFunc(casted, mapping)
+	case *tupleTerm:
+		return &tupleTerm{elems: substituteAll(casted.elems, mapping)}
+	case *structTerm:
+		fields := make([]fieldTerm, len(casted.fields))
+		for index, field := range casted.fields {
+			fields[index] = fieldTerm{name: field.name, typ: substitute(field.typ, mapping), embedded: field.embedded}
+		}
+		return &structTerm{fields: fields}
+	case *interfaceTerm:
+		methods := make(map[string]*funcTerm, len(casted.methods))
+		for name, method := range casted.methods {
+			methods[name] = substituteFunc(method, mapping)
+		}
+		return &interfaceTerm{methods: methods, embeds: substituteAll(casted.embeds, mapping)}
+	default:
+		return casted
+	}
+}
+
+func substituteAll(terms []term, mapping map[*typeParam]term) []term {
+	res := make([]term, len(terms))
+	for index, t := range terms {
+		res[index] = substitute(t, mapping)
+	}
+	return res
+}
+
+func substituteFunc(f *funcTerm, mapping map[*typeParam]term) *funcTerm {
+	return &funcTerm{params: substituteAll(f.params, mapping), results: substituteAll(f.results, mapping), variadic: f.variadic}
+}
+
+// call yield on each free variable of t (could yield the same variable several times)
+func freeVars(t term, yield func(*typeVar)) {
+	switch casted := prune(t).(type) {
+	case *typeVar:
+		yield(casted)
+	case *namedTerm:
+		for _, arg := range casted.args {
+			freeVars(arg, yield)
+		}
+	case *pointerTerm:
+		freeVars(casted.elem, yield)
+	case *sliceTerm:
+		freeVars(casted.elem, yield)
+	case *arrayTerm:
+		freeVars(casted.elem, yield)
+	case *mapTerm:
+		freeVars(casted.key, yield)
+		freeVars(casted.value, yield)
+	case *chanTerm:
+		freeVars(casted.elem, yield)
+	case *funcTerm:
+		for _, param := range casted.params {
+			freeVars(param, yield)
+		}
+		for _, result := range casted.results {
+			freeVars(result, yield)
+		}
+	case *tupleTerm:
+		for _, elem := range casted.elems {
+			freeVars(elem, yield)
+		}
+	case *structTerm:
+		for _, field := range casted.fields {
+			freeVars(field.typ, yield)
+		}
+	case *interfaceTerm:
+		for _, method := range casted.methods {
+			freeVars(method, yield)
+		}
+	}
+}
+
+func occurs(v *typeVar, t term) bool {
+	found := false
+	freeVars(t, func(current *typeVar) {
+		found = found || current == v
+	})
+	return found
+}
@@ -0,0 +1,281 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package infer
+
+import (
+	"errors"
+	"fmt"
+	gotypes "go/types"
+)
+
+const (
+	otherBasic basicKind = iota
+	boolBasic
+	stringBasic
+	intBasic
+	floatBasic
+	complexBasic
+)
+
+var (
+	errCyclicType = errors.New("cyclic type")
+	errLiteral    = errors.New("literal not compatible with")
+	errMismatch   = errors.New("type mismatch")
+)
+
+type basicKind uint8
+
+var basicKinds = map[string]basicKind{
+	"bool": boolBasic, "string": stringBasic,
+	"int": intBasic, "int8": intBasic, "int16": intBasic, "int32": intBasic, "int64": intBasic,
+	"uint": intBasic, "uint8": intBasic, "uint16": intBasic, "uint32": intBasic, "uint64": intBasic,
+	"uintptr": intBasic, "byte": intBasic, "rune": intBasic,
+	"float32": floatBasic, "float64": floatBasic, "complex64": complexBasic, "complex128": complexBasic,
+	"any": otherBasic, "comparable": otherBasic, "error": otherBasic,
+}
+
+var literalNames = [...]string{"", "int", "rune", "float", "nil", "string", "bool"}
+
+// alias of builtin types
+var canonicalNames = map[string]string{"byte": "uint8", "rune": "int32"}
+
+func canonicalName(name string) string {
+	if canonical, ok := canonicalNames[name]; ok {
+		return canonical
+	}
+	return name
+}
+
+func builtinType(name string) *namedTerm {
+	return &namedTerm{name: name}
+}
+
+func isBuiltin(n *namedTerm, name string) bool {
+	return n.path == "" && n.decl == nil && canonicalName(n.name) == canonicalName(name)
+}
+
+// follow local named type to their definition
+func underlying(t term) term {
+	for range 10 { // avoid infinite loop with wrong definition
+		named, ok := prune(t).(*namedTerm)
+		if !ok || named.decl == nil || named.decl.underlying == nil {
+			break
+		}
+		t = named.decl.instantiateUnderlying(named.arguments())
+	}
+	return prune(t)
+}
+
+func (i *inferer) unify(a term, b term) error {
+	a, b = prune(a), prune(b)
+	if a == b {
+		return nil
+	}
+
+	if casted, ok := a.(*typeVar); ok {
+		return i.bindVar(casted, b)
+	}
+	if casted, ok := b.(*typeVar); ok {
+		return i.bindVar(casted, a)
+	}
+
+	_, opaqueA := a.(opaqueTerm)
+	_, opaqueB := b.(opaqueTerm)
+	if opaqueA || opaqueB {
+		return nil
+	}
+
+	switch castedA := a.(type) {
+	case *namedTerm:
+		if castedB, ok := b.(*namedTerm); ok && castedA.path == castedB.path && castedA.decl == castedB.decl && canonicalName(castedA.name) == canonicalName(castedB.name) {
+			return i.unifyAll(castedA.arguments(), castedB.arguments())
+		}
+	case *pointerTerm:
+		if castedB, ok := b.(*pointerTerm); ok {
+			return i.unify(castedA.elem, castedB.elem)
+		}
+	case *sliceTerm:
+		if castedB, ok := b.(*sliceTerm); ok {
+			return i.unify(castedA.elem, castedB.elem)
+		}
+	case *arrayTerm:
+		if castedB, ok := b.(*arrayTerm); ok && (castedA.size < 0 || castedB.size < 0 || castedA.size == castedB.size) {
+			return i.unify(castedA.elem, castedB.elem)
+		}
+	case *mapTerm:
+		if castedB, ok := b.(*mapTerm); ok {
+			if err := i.unify(castedA.key, castedB.key); err != nil {
+				return err
+			}
+			return i.unify(castedA.value, castedB.value)
+		}
+	case *chanTerm:
+		if castedB, ok := b.(*chanTerm); ok && castedA.dir == castedB.dir {
+			return i.unify(castedA.elem, castedB.elem)
+		}
+	case *funcTerm:
+		if castedB, ok := b.(*funcTerm); ok && castedA.variadic == castedB.variadic {
+			if err := i.unifyAll(castedA.params, castedB.params); err != nil {
+				return err
+			}
+			return i.unifyAll(castedA.results, castedB.results)
+		}
+	case *tupleTerm:
+		if castedB, ok := b.(*tupleTerm); ok {
+			return i.unifyAll(castedA.elems, castedB.elems)
+		}
+	case *structTerm:
+		if castedB, ok := b.(*structTerm); ok && len(castedA.fields) == len(castedB.fields) {
+			for index, field := range castedA.fields {
+				if field.name != castedB.fields[index].name {
+					return fmt.Errorf("%w : %v and %v", errMismatch, a, b)
+				}
+				if err := i.unify(field.typ, castedB.fields[index].typ); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case *interfaceTerm:
+		if castedB, ok := b.(*interfaceTerm); ok && len(castedA.methods) == len(castedB.methods) {
+			for name, method := range castedA.methods {
+				otherMethod, ok := castedB.methods[name]
+				if !ok {
+					return fmt.Errorf("%w : %v and %v", errMismatch, a, b)
+				}
+				if err := i.unify(method, otherMethod); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%w : %v and %v", errMismatch, a, b)
+}
+
+func (i *inferer) unifyAll(as []term, bs []term) error {
+	if len(as) != len(bs) {
+		return fmt.Errorf("%w : %d and %d elements", errMismatch, len(as), len(bs))
+	}
+
+	for index, a := range as {
+		if err := i.unify(a, bs[index]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *inferer) bindVar(v *typeVar, t term) error {
+	if other, ok := t.(*typeVar); ok {
+		literal, err := mergeLiteral(v.literal, other.literal)
+		if err != nil {
+			return err
+		}
+
+		other.literal = literal
+		v.bound = other
+		if err := i.mergeOps(v, other); err != nil {
+			return err
+		}
+		return i.mergeMethods(v, other)
+	}
+
+	if occurs(v, t) {
+		return fmt.Errorf("%w : %v in %v", errCyclicType, v, t)
+	}
+	if !literalCompatible(v.literal, t) {
+		return fmt.Errorf("%w %v", errLiteral, t)
+	}
+
+	v.bound = t
+	if err := i.checkOps(v, t); err != nil {
+		return err
+	}
+	return i.checkMethods(v, t)
+}
+
+func mergeLiteral(a literalKind, b literalKind) (literalKind, error) {
+	switch {
+	case a == b, b == noLiteral:
+		return a, nil
+	case a == noLiteral:
+		return b, nil
+	case a > floatLiteral, b > floatLiteral:
+		return noLiteral, fmt.Errorf("%w %s", errLiteral, literalNames[max(a, b)])
+	}
+	return max(a, b), nil // int < rune < float
+}
+
+func literalCompatible(literal literalKind, t term) bool {
+	if literal == noLiteral {
+		return true
+	}
+
+	switch casted := underlying(t).(type) {
+	case *typeParam, opaqueTerm:
+		return true
+	case *namedTerm:
+		kind, known := basicKinds[canonicalName(casted.name)]
+		if casted.path != "" || !known {
+			return true // external type, unknown definition
+		}
+
+		switch literal {
+		case intLiteral, runeLiteral:
+			return kind == intBasic || kind == floatBasic || kind == complexBasic
+		case floatLiteral:
+			return kind == floatBasic || kind == complexBasic
+		case nilLiteral:
+			return kind == otherBasic
+		case stringLiteral:
+			return kind == stringBasic
+		case boolLiteral:
+			return kind == boolBasic
+		}
+	case *pointerTerm, *sliceTerm, *mapTerm, *chanTerm, *funcTerm, *interfaceTerm:
+		return literal == nilLiteral
+	}
+	return false
+}
+
+func defaultLiteralType(literal literalKind) term {
+	switch literal {
+	case intLiteral:
+		return builtinType("int")
+	case runeLiteral:
+		return builtinType("rune")
+	case floatLiteral:
+		return builtinType("float64")
+	case stringLiteral:
+		return builtinType("string")
+	case boolLiteral:
+		return builtinType("bool")
+	}
+	return nil
+}
+
+// true when values of any type can be assigned to t
+func isInterface(t term) bool {
+	switch casted := underlying(t).(type) {
+	case *interfaceTerm:
+		return true
+	case *namedTerm:
+		if casted.goType != nil {
+			return gotypes.IsInterface(casted.goType)
+		}
+		return isBuiltin(casted, "any") || isBuiltin(casted, "error")
+	}
+	return false
+}
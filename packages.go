@@ -0,0 +1,443 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/dvaumoron/foresee/builtins/compile"
+	"github.com/dvaumoron/foresee/builtins/eval"
+	"github.com/dvaumoron/foresee/builtins/names"
+	"github.com/dvaumoron/foresee/diagnostic"
+	"github.com/dvaumoron/foresee/infer"
+	"github.com/dvaumoron/foresee/parser"
+	"github.com/dvaumoron/foresee/types"
+)
+
+const (
+	unvisited visitState = iota
+	visiting
+	visited
+)
+
+type visitState uint8
+
+// files of a directory, they belong to the same package
+type packageFiles struct {
+	dir       string
+	path      string // import path
+	filePaths []string
+	requested map[string]bool // files to generate, every file when nil (dependency)
+	expandeds []*types.List
+	sums      []string // hashes of the files content
+	deps      []*packageFiles
+	goDirs    []string // imported packages of the module without foresee sources
+	failed    bool
+	state     visitState
+	key       string        // see computeKey
+	dirty     bool          // files must be generated
+	needed    bool          // inferred for a dirty dependent
+	log       bytes.Buffer  // messages, printed in the order of packages
+	done      chan struct{} // closed when the package is processed
+	failures  int           // files which could not be generated
+}
+
+// packages of the module by directory
+type packageLoader struct {
+	parser     *parser.Parser
+	modulePath string
+	byDir      map[string]*packageFiles
+	loaded     []*packageFiles // waiting for the loading of their files
+	order      []*packageFiles // dependencies first
+	limiter    chan struct{}   // bound the number of parallel tasks
+	cache      *buildCache
+	opts       *options
+	imports    *checkImporter // used in check mode
+}
+
+// process the files with the packages they depend on (in the same module),
+// opts.jobs files are handled in parallel, unchanged packages are skipped unless opts.force is set,
+// return the number of files which could not be generated
+func processPackages(filePaths []string, opts *options) int {
+	infer.ResetPackages() // a watch cycle must see the changes
+	moduleName, _ := compile.Builtins.LoadStr(names.HiddenModule)
+	modulePath, _ := moduleName.(types.String)
+	loader := &packageLoader{
+		parser: parser.New(), modulePath: string(modulePath),
+		byDir: map[string]*packageFiles{}, limiter: make(chan struct{}, max(opts.jobs, 1)),
+		cache: loadCache(opts.force), opts: opts, imports: newCheckImporter(),
+	}
+
+	var requested []*packageFiles
+	for _, filePath := range filePaths {
+		filePath = filepath.Clean(filePath)
+		pkg := loader.packageAt(filepath.Dir(filePath))
+		if pkg.requested == nil {
+			// the other files of the package are needed by the inference
+			pkg.filePaths, _ = filepath.Glob(filepath.Join(pkg.dir, "*"+fileExt))
+			pkg.requested = map[string]bool{}
+			requested = append(requested, pkg)
+			loader.loaded = append(loader.loaded, pkg)
+		}
+		pkg.requested[filePath] = true
+		if !slices.Contains(pkg.filePaths, filePath) {
+			pkg.filePaths = append(pkg.filePaths, filePath)
+		}
+	}
+
+	// each step load the packages discovered by the previous one
+	for len(loader.loaded) != 0 {
+		loader.loadAll()
+	}
+	for _, pkg := range requested {
+		loader.visit(pkg, nil)
+	}
+	loader.markDirty()
+
+	for _, pkg := range loader.order {
+		pkg.done = make(chan struct{})
+	}
+	for _, pkg := range loader.order {
+		go loader.processPackage(pkg)
+	}
+
+	failed, written := 0, false
+	for _, pkg := range loader.order {
+		<-pkg.done
+		os.Stderr.Write(pkg.log.Bytes())
+		failed += pkg.failures
+		if pkg.dirty && !pkg.failed && !opts.check {
+			for _, filePath := range pkg.outputs() {
+				loader.cache.Files[filePath] = pkg.key
+			}
+			written = true
+		}
+	}
+
+	if written {
+		if err := loader.cache.save(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error while writing cache :", err)
+		}
+	}
+	return failed
+}
+
+// the requested files of a package given on the command line, every file of a dependency
+func (p *packageFiles) generates(filePath string) bool {
+	return p.requested == nil || p.requested[filePath]
+}
+
+func (p *packageFiles) outputs() []string {
+	var res []string
+	for _, filePath := range p.filePaths {
+		if p.generates(filePath) {
+			res = append(res, filePath)
+		}
+	}
+	return res
+}
+
+// compute the keys (dependencies first), then mark the packages to generate (or check)
+// and the ones their inference need
+func (l *packageLoader) markDirty() {
+	for _, pkg := range l.order {
+		if !pkg.failed {
+			pkg.key = computeKey(pkg)
+			pkg.dirty = l.opts.selected(pkg.dir) && (l.opts.check || !l.cache.upToDate(pkg, l.opts))
+		}
+	}
+	for _, pkg := range slices.Backward(l.order) {
+		if pkg.dirty || pkg.needed {
+			for _, dep := range pkg.deps {
+				dep.needed = true
+			}
+		}
+	}
+}
+
+// run task when the limiter allows it
+func (l *packageLoader) run(wg *sync.WaitGroup, task func()) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		l.limiter <- struct{}{}
+		defer func() {
+			<-l.limiter
+		}()
+		task()
+	}()
+}
+
+func (l *packageLoader) packageAt(dir string) *packageFiles {
+	dir = filepath.Clean(dir)
+	pkg, ok := l.byDir[dir]
+	if !ok {
+		path := l.modulePath
+		if dir != "." {
+			path += "/" + filepath.ToSlash(dir)
+		}
+		pkg = &packageFiles{dir: dir, path: path}
+		l.byDir[dir] = pkg
+	}
+	return pkg
+}
+
+// parse and expand the files of the waiting packages, then register the packages of the module they import
+func (l *packageLoader) loadAll() {
+	pkgs := l.loaded
+	l.loaded = nil
+
+	var wg sync.WaitGroup
+	fileLogs := make([][]bytes.Buffer, len(pkgs))
+	for pkgIndex, pkg := range pkgs {
+		pkg.expandeds = make([]*types.List, len(pkg.filePaths))
+		pkg.sums = make([]string, len(pkg.filePaths))
+		fileLogs[pkgIndex] = make([]bytes.Buffer, len(pkg.filePaths))
+		for index, filePath := range pkg.filePaths {
+			l.run(&wg, func() {
+				pkg.expandeds[index], pkg.sums[index] = l.loadFile(&fileLogs[pkgIndex][index], filePath)
+			})
+		}
+	}
+	wg.Wait()
+
+	for pkgIndex, pkg := range pkgs {
+		for index := range pkg.filePaths {
+			pkg.log.Write(fileLogs[pkgIndex][index].Bytes())
+			if pkg.expandeds[index] == nil {
+				pkg.failed = true
+			}
+		}
+		if pkg.failed {
+			fmt.Fprintln(&pkg.log, "Error while reading package", pkg.dir, ": no file written")
+			continue
+		}
+
+		for _, expanded := range pkg.expandeds {
+			for _, path := range infer.ImportPaths(expanded) {
+				dir, ok := l.moduleDir(path)
+				if !ok {
+					continue
+				}
+
+				if dep := l.localPackage(dir); dep == nil {
+					if !slices.Contains(pkg.goDirs, dir) {
+						pkg.goDirs = append(pkg.goDirs, dir)
+					}
+				} else if dep != pkg && !slices.Contains(pkg.deps, dep) {
+					pkg.deps = append(pkg.deps, dep)
+				}
+			}
+		}
+	}
+}
+
+// return the expanded file (nil when it could not be parsed or expanded) and the hash of its content
+func (l *packageLoader) loadFile(out io.Writer, filePath string) (*types.List, string) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		fmt.Fprintln(out, "Error while opening", filePath, ":", err)
+		return nil, ""
+	}
+
+	sum := hashData(data)
+	parsed, err := l.parser.Parse(filePath, bytes.NewReader(data))
+	if err != nil {
+		fmt.Fprintln(out, "Error while parsing", filePath, ":", err)
+		return nil, sum
+	}
+
+	expanded, err := eval.ExpandMacro(parsed)
+	if err != nil {
+		fmt.Fprintln(out, "Error while expanding", filePath, ":", err)
+	}
+	return expanded, sum
+}
+
+// directory of the package of the module matching path
+func (l *packageLoader) moduleDir(path string) (string, bool) {
+	switch {
+	case path == l.modulePath:
+		return ".", true
+	case strings.HasPrefix(path, l.modulePath+"/"):
+		return filepath.FromSlash(path[len(l.modulePath)+1:]), true
+	}
+	return "", false
+}
+
+// return the package with foresee sources in dir (nil otherwise),
+// a package seen for the first time wait to be loaded
+func (l *packageLoader) localPackage(dir string) *packageFiles {
+	pkg := l.packageAt(dir)
+	if len(pkg.filePaths) == 0 { // neither requested nor seen
+		if pkg.filePaths, _ = filepath.Glob(filepath.Join(pkg.dir, "*"+fileExt)); len(pkg.filePaths) == 0 {
+			return nil // Go package
+		}
+		l.loaded = append(l.loaded, pkg)
+	}
+	return pkg
+}
+
+// depth first traversal to order the packages and detect import cycles
+func (l *packageLoader) visit(pkg *packageFiles, stack []*packageFiles) {
+	if pkg.state != unvisited {
+		return
+	}
+
+	pkg.state = visiting
+	stack = append(stack, pkg)
+	for _, dep := range pkg.deps {
+		if dep.state == visiting {
+			reportCycle(stack, dep)
+			continue
+		}
+		l.visit(dep, stack)
+	}
+	pkg.state = visited
+	l.order = append(l.order, pkg)
+}
+
+// mark the packages of the cycle (from dep to the end of stack) as failed
+func reportCycle(stack []*packageFiles, dep *packageFiles) {
+	var builder strings.Builder
+	start := len(stack) - 1
+	for stack[start] != dep {
+		start--
+	}
+	for _, pkg := range stack[start:] {
+		pkg.failed = true
+		builder.WriteString(pkg.path)
+		builder.WriteString(" -> ")
+	}
+	builder.WriteString(dep.path)
+	fmt.Fprintln(&dep.log, "Error import cycle :", builder.String())
+}
+
+// infer pkg once its dependencies are processed, then generate its files in parallel
+func (l *packageLoader) processPackage(pkg *packageFiles) {
+	defer close(pkg.done)
+
+	if pkg.failed {
+		pkg.failures = len(pkg.outputs())
+		return
+	}
+	if !pkg.dirty && !pkg.needed {
+		if l.opts.verbose && l.opts.selected(pkg.dir) {
+			fmt.Fprintln(&pkg.log, "Up to date", pkg.dir)
+		}
+		return
+	}
+	for _, dep := range pkg.deps {
+		<-dep.done
+	}
+	for _, dep := range pkg.deps {
+		if dep.failed {
+			pkg.failed, pkg.failures = true, len(pkg.outputs())
+			fmt.Fprintln(&pkg.log, "Error while infering package", pkg.dir, ": dependency", dep.path, "failed, no file written")
+			return
+		}
+	}
+
+	var wg sync.WaitGroup
+	var infereds []*types.List
+	var err error
+	l.run(&wg, func() {
+		infereds, err = infer.InferPackage(pkg.path, pkg.expandeds)
+	})
+	wg.Wait()
+	if err != nil {
+		pkg.failed, pkg.failures = true, len(pkg.outputs())
+		fmt.Fprintln(&pkg.log, err) // one diagnostic by line
+		fmt.Fprintln(&pkg.log, "Error while infering package", pkg.dir, ": no file written")
+		return
+	}
+	if !pkg.dirty {
+		return
+	}
+	if l.opts.outputDir != "" && !l.opts.check {
+		paths, err := l.outputImports(pkg)
+		if err != nil {
+			pkg.failed, pkg.failures = true, len(pkg.outputs())
+			fmt.Fprintln(&pkg.log, "Error while writing package", pkg.dir, ":", err)
+			return
+		}
+		for _, infered := range infereds {
+			infer.ReplaceImports(infered, paths)
+		}
+	}
+
+	// the check of the generated Go need every file of the package
+	fileLogs := make([]bytes.Buffer, len(pkg.filePaths))
+	rendered := make([][]byte, len(pkg.filePaths))
+	skipped := make([]bool, len(pkg.filePaths))
+	for index, filePath := range pkg.filePaths {
+		if skipped[index] = !l.opts.check && !pkg.generates(filePath); skipped[index] {
+			continue
+		}
+		l.run(&wg, func() {
+			rendered[index] = generateFile(&fileLogs[index], filePath, infereds[index], l.opts)
+		})
+	}
+	wg.Wait()
+
+	for index := range pkg.filePaths {
+		pkg.log.Write(fileLogs[index].Bytes())
+		if rendered[index] == nil && !skipped[index] {
+			pkg.failures++
+		}
+	}
+	if pkg.failed = pkg.failures != 0; pkg.failed || !l.opts.check {
+		return
+	}
+
+	// the generated Go must be valid too
+	var diagnostics diagnostic.List
+	l.run(&wg, func() {
+		diagnostics = typeCheck(pkg, infereds, rendered, l.opts, l.imports)
+	})
+	wg.Wait()
+
+	failedFiles := map[string]struct{}{}
+	for _, d := range diagnostics {
+		fmt.Fprintln(&pkg.log, d)
+		failedFiles[d.Span.File] = struct{}{}
+	}
+	pkg.failures = len(failedFiles)
+	pkg.failed = pkg.failures != 0
+}
+
+// import paths of the generated dependencies (under the output directory) by the paths of their sources
+func (l *packageLoader) outputImports(pkg *packageFiles) (map[string]string, error) {
+	paths := make(map[string]string, len(pkg.deps))
+	for _, dep := range pkg.deps {
+		dir := filepath.Join(l.opts.outputDir, dep.dir)
+		if filepath.IsAbs(dir) {
+			if wd, err := os.Getwd(); err == nil {
+				dir, _ = filepath.Rel(wd, dir)
+			}
+		}
+		if !filepath.IsLocal(dir) {
+			return nil, errOutputImport
+		}
+		paths[dep.path] = l.modulePath + "/" + filepath.ToSlash(dir)
+	}
+	return paths, nil
+}
@@ -0,0 +1,96 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/dvaumoron/foresee/parser/stack"
+	"github.com/dvaumoron/foresee/types"
+)
+
+// spaces by indentation level in formatted files
+const indentWidth = 4
+
+// Format normalize the indentation of a file (indentWidth spaces by level),
+// remove the trailing spaces, merge consecutive blank lines
+// and place comment lines at the level of the following line (the parsed code is unchanged).
+func Format(fileName string, reader io.Reader) ([]byte, error) {
+	var res bytes.Buffer
+	writeLine := func(level int, text string) {
+		if text != "" {
+			res.WriteString(strings.Repeat(" ", level*indentWidth))
+			res.WriteString(text)
+		}
+		res.WriteByte('\n')
+	}
+
+	indentStack := stack.New[int]()
+	indentStack.Push(0)
+
+	var pending []string // comment and blank (empty string) lines waiting for the level of the next line
+	scanner := bufio.NewScanner(reader)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		switch trimmed := strings.TrimSpace(line); {
+		case trimmed == "":
+			if size := len(pending); (size != 0 && pending[size-1] != "") || (size == 0 && res.Len() != 0) {
+				pending = append(pending, "")
+			}
+			continue
+		case trimmed[0] == '#':
+			pending = append(pending, trimmed)
+			continue
+		}
+
+		trimmed := strings.TrimLeft(line, " ")
+		index := len(line) - len(trimmed)
+		if trimmed[0] == '\t' {
+			return nil, locatedError(types.Span{File: fileName, Start: types.Position{Line: lineNumber, Col: index + 1}}, errTab)
+		}
+
+		if top := indentStack.Peek(); top < index {
+			indentStack.Push(index)
+		} else {
+			for top > index {
+				indentStack.Pop()
+				top = indentStack.Peek()
+			}
+			if top < index {
+				return nil, locatedError(types.Span{File: fileName, Start: types.Position{Line: lineNumber, Col: index + 1}}, errIndent)
+			}
+		}
+
+		level := indentStack.Size() - 1
+		for _, text := range pending {
+			writeLine(level, text)
+		}
+		pending = pending[:0]
+		writeLine(level, trimmed)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for size := len(pending); size != 0 && pending[size-1] == ""; size-- {
+		pending = pending[:size-1]
+	}
+	for _, text := range pending {
+		writeLine(0, text)
+	}
+	return res.Bytes(), nil
+}
@@ -16,6 +16,7 @@ package parser
 import (
 	"bufio"
 	"errors"
+	"fmt"
 	"io"
 	"iter"
 	"slices"
@@ -30,56 +31,119 @@ import (
 var (
 	errIndent = errors.New("identation not consistent")
 	errNode   = errors.New("unhandled node")
+	errRule   = errors.New("wait a rule form with a pattern list")
 	errTab    = errors.New("tabulation not allowed in indentation")
 )
 
-func Parse(reader io.Reader) (*types.List, error) {
-	var err error
-	nodes := slices.Collect(splitIndentToSyntax(reader, func(innerErr error) {
+// fileName is only used to locate nodes and errors,
+// the rules declared in the file apply to the following lines of the same file
+func (p *Parser) Parse(fileName string, reader io.Reader) (res *types.List, err error) {
+	nodes := slices.Collect(splitIndentToSyntax(fileName, reader, func(innerErr error) {
 		err = innerErr
 	}))
 	if err != nil {
 		return nil, err
 	}
 
-	res := types.NewList(names.FileId)
-	if err := processNodes(nodes, res); err != nil {
+	var current types.Span
+	defer func() {
+		if r := recover(); r != nil {
+			casted, ok := r.(error)
+			if !ok {
+				panic(r)
+			}
+			res, err = nil, locatedError(current, casted)
+		}
+	}()
+
+	state := &parsing{customParsers: p.customParsers, env: types.MakeLocalEnvironment(BuiltinsCopy)}
+	res = types.NewList(names.FileId).SetSpan(types.Span{File: fileName})
+	err = state.forEachObject(nodes, func(object types.Object, span types.Span) {
+		current = span
+		casted, ok := object.(*types.List)
+		if !ok {
+			// each line is a list, except when replaced by a rule result
+			panic(errRulePlace)
+		}
+
+		if header, _ := casted.LoadInt(0).(types.Identifier); header == names.Rule {
+			pattern, ok := casted.LoadInt(2).(*types.List)
+			rule, ok2 := casted.Eval(state.env).(types.Appliable)
+			if !ok || !ok2 {
+				panic(errRule)
+			}
+			state.fileParsers = append(state.fileParsers, makePatternParser(pattern, rule))
+			return
+		}
+		res.AddWithSpan(object, span)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
-func processNodes(nodes []split.Node, list *types.List) error {
+func (p *parsing) processNodes(nodes []split.Node, list *types.List) error {
+	return p.forEachObject(nodes, func(object types.Object, span types.Span) {
+		list.AddWithSpan(object, span)
+	})
+}
+
+// call handle with each object parsed from nodes
+func (p *parsing) forEachObject(nodes []split.Node, handle func(types.Object, types.Span)) error {
 	for i, last := 0, len(nodes); i < last; {
-		switch object, consumed := handleSlice(nodes[i:]); consumed {
+		switch object, consumed := p.handleSlice(nodes[i:]); consumed {
 		case -1: // separator marker
 			i += 1
 		case 0:
-			return errNode
+			return locatedError(nodes[i].Span(), errNode)
 		default:
-			list.Add(object)
+			span := spanOf(nodes[i : i+consumed])
+			if casted, ok := object.(*types.List); ok && !casted.Span().IsValid() {
+				// list built by a rule
+				casted.SetSpan(span)
+			}
+			handle(object, span)
 			i += consumed
 		}
 	}
 	return nil
 }
 
-func yieldClosingParenthesis(yield func(rune) bool) bool {
-	return yield(')')
+func locatedError(span types.Span, err error) error {
+	return fmt.Errorf("%v : %w", span, err)
+}
+
+// merge the spans of the nodes (separators have no span)
+func spanOf(nodes []split.Node) types.Span {
+	var res types.Span
+	for _, node := range nodes {
+		res = res.Merge(node.Span())
+	}
+	return res
 }
 
-func yieldNothing(yield func(rune) bool) bool {
+func yieldClosingParenthesis(yield func(rune, types.Position) bool, pos types.Position) bool {
+	return yield(')', pos)
+}
+
+func yieldNothing(yield func(rune, types.Position) bool, pos types.Position) bool {
 	return true
 }
 
-func indentToSyntax(reader io.Reader, registerError func(error)) iter.Seq[rune] {
+// added parenthesis are located at the first character of the line when opening
+// and at the last character of the previous line when closing
+func indentToSyntax(fileName string, reader io.Reader, registerError func(error)) iter.Seq2[rune, types.Position] {
 	closePreviousLine := yieldNothing
 	indentStack := stack.New[int]()
 	indentStack.Push(0)
 
 	scanner := bufio.NewScanner(reader)
-	return func(yield func(rune) bool) {
+	return func(yield func(rune, types.Position) bool) {
+		lineNumber := 0
+		var lastPos types.Position
 		for scanner.Scan() {
+			lineNumber++
 			line := scanner.Text()
 			if trimmed := strings.TrimSpace(line); trimmed != "" && trimmed[0] != '#' {
 				index, char := 0, rune(0)
@@ -88,46 +152,51 @@ func indentToSyntax(reader io.Reader, registerError func(error)) iter.Seq[rune]
 					case ' ':
 						continue
 					case '\t':
-						registerError(errTab)
+						registerError(locatedError(types.Span{File: fileName, Start: types.Position{Line: lineNumber, Col: index + 1}}, errTab))
 						return
 					}
 
 					if top := indentStack.Peek(); top < index {
 						indentStack.Push(index)
 					} else {
-						if !closePreviousLine(yield) {
+						if !closePreviousLine(yield, lastPos) {
 							return
 						}
 						if top > index {
 							indentStack.Pop()
 							for top = indentStack.Peek(); top > index; top = indentStack.Peek() {
-								if !yield(')') {
+								if !yield(')', lastPos) {
 									return
 								}
 								indentStack.Pop()
 							}
 							if top < index {
-								registerError(errIndent)
+								registerError(locatedError(types.Span{File: fileName, Start: types.Position{Line: lineNumber, Col: index + 1}}, errIndent))
 								return
 							}
-							if !yield(')') {
+							if !yield(')', lastPos) {
 								return
 							}
 						}
 					}
-					if !yield('(') {
+					if !yield('(', types.Position{Line: lineNumber, Col: index + 1}) {
 						return
 					}
 					break
 				}
 
-				for _, char := range line[index:] {
+				for offset, char := range line[index:] {
 					if char == '#' {
 						break
 					}
-					if !yield(char) {
+
+					pos := types.Position{Line: lineNumber, Col: index + offset + 1}
+					if !yield(char, pos) {
 						return
 					}
+					if char != ' ' {
+						lastPos = pos
+					}
 				}
 				closePreviousLine = yieldClosingParenthesis
 			}
@@ -139,13 +208,13 @@ func indentToSyntax(reader io.Reader, registerError func(error)) iter.Seq[rune]
 		}
 
 		for range indentStack.Size() {
-			if !yield(')') {
+			if !yield(')', lastPos) {
 				return
 			}
 		}
 	}
 }
 
-func splitIndentToSyntax(reader io.Reader, registerError func(error)) iter.Seq[split.Node] {
-	return split.SmartSplit(indentToSyntax(reader, registerError), registerError)
+func splitIndentToSyntax(fileName string, reader io.Reader, registerError func(error)) iter.Seq[split.Node] {
+	return split.SmartSplit(fileName, indentToSyntax(fileName, reader, registerError), registerError)
 }
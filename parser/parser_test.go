@@ -0,0 +1,165 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package parser_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dvaumoron/foresee/builtins/debug"
+	_ "github.com/dvaumoron/foresee/builtins/eval" // rules are evaluated with the eval builtins
+	"github.com/dvaumoron/foresee/parser"
+	"github.com/dvaumoron/foresee/types"
+)
+
+// parse source and return the display of each top level node
+func parseLines(t *testing.T, source string) []string {
+	t.Helper()
+
+	l, err := parser.New().Parse("test.fc", strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for node := range l.Iter() {
+		var buffer strings.Builder
+		node.Eval(debug.DebugEnvironment{}).Render(&buffer)
+		lines = append(lines, buffer.String())
+	}
+	return lines
+}
+
+func checkLines(t *testing.T, source string, expected []string) {
+	t.Helper()
+
+	lines := parseLines(t, source)
+	if len(lines) != len(expected) {
+		t.Fatalf("got %d nodes, want %d : %q", len(lines), len(expected), lines)
+	}
+	for index, line := range lines {
+		if line != expected[index] {
+			t.Errorf("node %d : got %s, want %s", index, line, expected[index])
+		}
+	}
+}
+
+func TestStructTagAndQuasiquote(t *testing.T) {
+	source := `package p
+
+type Pt struct
+    X int "json:\"x\""
+    Y int json:"y"
+
+macro Twice (x)
+    return ` + "`" + `(+ ,x ,x)
+
+var a (Twice 2)
+`
+	checkLines(t, source, []string{
+		"file",
+		"(package p)",
+		`(type Pt struct (X int "json:\"x\"") (Y int (list json "y")))`,
+		"(macro Twice (x) (return (quasiquote (+ (unquote x) (unquote x)))))",
+		"(var a (Twice 2))",
+	})
+}
+
+func TestRuleCoveringLine(t *testing.T) {
+	source := `package p
+
+rule swap ("swap" a b)
+    return ` + "`" + `(= (,a ,b) ,b ,a)
+
+func F(x:int y:int) int
+    swap x y
+    return x
+`
+	checkLines(t, source, []string{
+		"file",
+		"(package p)",
+		"(func F ((list x int) (list y int)) int (= (x y) y x) (return x))",
+	})
+}
+
+func TestRuleResultNotPlaceable(t *testing.T) {
+	source := `package p
+
+rule three ("three")
+    return 3
+
+var a
+    three 4
+`
+	_, err := parser.New().Parse("test.fc", strings.NewReader(source))
+	if err == nil || !strings.Contains(err.Error(), "placed") {
+		t.Errorf("wait a placement error, got %v", err)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	l, err := parser.New().Parse("test.fc", strings.NewReader(`package p
+
+var a (+ 1 2)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	varList, ok := l.LoadInt(2).(*types.List)
+	if !ok {
+		t.Fatalf("wait a list, got %v", l.LoadInt(2))
+	}
+	if start := varList.Span().Start; start != (types.Position{Line: 3, Col: 1}) {
+		t.Errorf("got line start %v, want 3:1", start)
+	}
+	if start := varList.SpanInt(2).Start; start != (types.Position{Line: 3, Col: 7}) {
+		t.Errorf("got value start %v, want 3:7", start)
+	}
+	if file := varList.Span().File; file != "test.fc" {
+		t.Errorf("got file %q, want test.fc", file)
+	}
+}
+
+// lists built for the parts of "name:type" keep their position
+func TestSplitPartSpans(t *testing.T) {
+	l, err := parser.New().Parse("test.fc", strings.NewReader(`package p
+
+func F(a:map[string]int b:[]Pt:c) int
+    return 0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	funcList, _ := l.LoadInt(2).(*types.List)
+	params, _ := funcList.LoadInt(2).(*types.List)
+	first, _ := params.LoadInt(0).(*types.List)
+	second, _ := params.LoadInt(1).(*types.List)
+	for _, check := range []struct {
+		part  types.Object
+		start types.Position
+	}{
+		{part: first.LoadInt(2), start: types.Position{Line: 3, Col: 10}},
+		{part: second.LoadInt(2), start: types.Position{Line: 3, Col: 27}},
+	} {
+		located, ok := check.part.(*types.List)
+		if !ok {
+			t.Fatalf("wait a list, got %v", check.part)
+		}
+		if start := located.Span().Start; start != check.start {
+			t.Errorf("got type start %v, want %v", start, check.start)
+		}
+	}
+}
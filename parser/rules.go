@@ -14,6 +14,9 @@
 package parser
 
 import (
+	"errors"
+	"iter"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -23,47 +26,181 @@ import (
 )
 
 var (
-	sliceParsers []SliceParser
+	defaultParsers []sliceParser
 
 	// an empty environment to execute custom rules
 	BuiltinsCopy types.Environment = types.MakeBaseEnvironment()
+
+	errRulePlace  = errors.New("rule result can not be placed")
+	errRuleResult = errors.New("wait a rule returning None or a list with the node and the count of consumed nodes")
 )
 
-type SliceParser = func([]split.Node) (types.Object, int)
+type sliceParser = func(*parsing, []split.Node) (types.Object, int)
 
 // needed to prevent a cycle in the initialisation
 func init() {
-	sliceParsers = []SliceParser{
-		skipSeparator, parseTrue, parseFalse, parseNone, parseString, parseRune, parseInt, parseFloat, parseUnquote, parseList, parseEllipsis, parseDotField,
-		parseLiteral, parseTilde, parseAddressing, parseDereference, parseNot, parseArrowChanType, parseChanArrowType, parseChanType, parseArrayOrSliceType,
-		parseMapType, parseFuncType, parseGenericType,
+	defaultParsers = []sliceParser{
+		(*parsing).skipSeparator, (*parsing).parseTrue, (*parsing).parseFalse, (*parsing).parseNone, (*parsing).parseString,
+		(*parsing).parseRune, (*parsing).parseInt, (*parsing).parseFloat, (*parsing).parseQuasiquote, (*parsing).parseUnquote,
+		(*parsing).parseList, (*parsing).parseEllipsis, (*parsing).parseDotField, (*parsing).parseLiteral, (*parsing).parseTilde,
+		(*parsing).parseAddressing, (*parsing).parseDereference, (*parsing).parseNot, (*parsing).parseArrowChanType,
+		(*parsing).parseChanArrowType, (*parsing).parseChanType, (*parsing).parseArrayOrSliceType, (*parsing).parseMapType,
+		(*parsing).parseFuncType, (*parsing).parseStructType, (*parsing).parseInterfaceType, (*parsing).parseGenericType,
 	}
 }
 
-// Must be called before the parsing of files to affect them
-func AddCustomRule(rule types.Appliable) {
-	// TODO use a mutex ? (will macro or parsing run concurrently ???)
-	sliceParsers = append(sliceParsers, func(sliced []split.Node) (types.Object, int) {
-		args := types.NewList(types.String("todo"))
-		node := rule.Apply(BuiltinsCopy, args.Iter())
-		// The Apply must return None if it fails.
+// A Parser is safe for concurrent use (each parse has its own state).
+type Parser struct {
+	customParsers []sliceParser
+}
+
+// Custom rules are tried before the default ones,
+// each rule receives the following nodes (each one parsed alone, separators are skipped)
+// and must return None when it does not match or (list node consumed).
+func New(customRules ...types.Appliable) *Parser {
+	customParsers := make([]sliceParser, len(customRules))
+	for index, rule := range customRules {
+		customParsers[index] = makeCustomParser(rule)
+	}
+	return &Parser{customParsers: customParsers}
+}
+
+// state of the parse of a file
+type parsing struct {
+	customParsers []sliceParser
+	fileParsers   []sliceParser     // rules declared in the file
+	env           types.Environment // where custom rules are executed
+}
+
+func makeCustomParser(rule types.Appliable) sliceParser {
+	return func(p *parsing, sliced []split.Node) (types.Object, int) {
+		if k, _, _ := sliced[0].Cast(); k == split.SeparatorKind {
+			return nil, 0
+		}
+
+		result := rule.Apply(p.env, p.parsedNodes(sliced))
+		if _, isNone := result.(types.NoneType); isNone {
+			return nil, 0
+		}
+
+		resultList, ok := result.(*types.List)
+		count, ok2 := resultList.LoadInt(1).(types.Integer)
+		if !ok || !ok2 || resultList.Size() != 2 || count <= 0 {
+			panic(errRuleResult)
+		}
+		return resultList.LoadInt(0), rawCount(sliced, int(count))
+	}
+}
+
+// pattern elements are String matching a word or Identifier matching any node,
+// rule receives the parsed nodes matching identifiers and return the node (or None)
+func makePatternParser(pattern *types.List, rule types.Appliable) sliceParser {
+	return func(p *parsing, sliced []split.Node) (types.Object, int) {
+		if k, _, _ := sliced[0].Cast(); k == split.SeparatorKind {
+			return nil, 0
+		}
+
+		var matched []split.Node
+		index := 0
+		for elem := range pattern.Iter() {
+			for index < len(sliced) {
+				if k, _, _ := sliced[index].Cast(); k != split.SeparatorKind {
+					break
+				}
+				index++
+			}
+			if index == len(sliced) {
+				return nil, 0
+			}
+
+			node := sliced[index]
+			if word, ok := elem.(types.String); ok {
+				if k, s, _ := node.Cast(); k != split.StringKind || s != string(word) {
+					return nil, 0
+				}
+			} else {
+				matched = append(matched, node)
+			}
+			index++
+		}
+
+		// parse only when the whole pattern match
+		args := make([]types.Object, len(matched))
+		for i, node := range matched {
+			args[i], _ = p.handleDefaultSlice([]split.Node{node})
+		}
+
+		node := rule.Apply(p.env, slices.Values(args))
 		if _, isNone := node.(types.NoneType); isNone {
-			return types.None, 0
+			return nil, 0
 		}
-		return types.None, 1
-	})
+		return node, index
+	}
+}
+
+// lazily parse each node alone (skipping separators)
+func (p *parsing) parsedNodes(sliced []split.Node) iter.Seq[types.Object] {
+	return func(yield func(types.Object) bool) {
+		for _, node := range sliced {
+			if k, _, _ := node.Cast(); k == split.SeparatorKind {
+				continue
+			}
+
+			object, _ := p.handleDefaultSlice([]split.Node{node})
+			if !yield(object) {
+				return
+			}
+		}
+	}
+}
+
+// number of nodes (including separators) to have count nodes without separator
+func rawCount(sliced []split.Node, count int) int {
+	for index, node := range sliced {
+		if k, _, _ := node.Cast(); k != split.SeparatorKind {
+			if count--; count == 0 {
+				return index + 1
+			}
+		}
+	}
+	return len(sliced)
 }
 
 // try to apply parsing rule in order (including custom rules),
 // fallback to an identifier when nothing matches
-func handleSlice(sliced []split.Node) (types.Object, int) {
+func (p *parsing) handleSlice(sliced []split.Node) (types.Object, int) {
+	if len(sliced) == 0 {
+		return types.None, 0
+	}
+
+	if node, consumed := p.handleRuleSlice(sliced); consumed != 0 {
+		return node, consumed
+	}
+	return p.handleDefaultSlice(sliced)
+}
+
+// custom rules are tried first to allow overriding the default ones
+func (p *parsing) handleRuleSlice(sliced []split.Node) (types.Object, int) {
+	for _, parsers := range [...][]sliceParser{p.customParsers, p.fileParsers} {
+		for _, parser := range parsers {
+			if node, consumed := parser(p, sliced); consumed != 0 {
+				return node, consumed
+			}
+		}
+	}
+	return nil, 0
+}
+
+// parse without the custom rules at top level (used for the arguments of custom rules),
+// they still apply inside the lists
+func (p *parsing) handleDefaultSlice(sliced []split.Node) (types.Object, int) {
 	size := len(sliced)
 	if size == 0 {
 		return types.None, 0
 	}
 
-	for _, parser := range sliceParsers {
-		if node, consumed := parser(sliced); consumed != 0 {
+	for _, parser := range defaultParsers {
+		if node, consumed := parser(p, sliced); consumed != 0 {
 			return node, consumed
 		}
 	}
@@ -74,8 +211,17 @@ func handleSlice(sliced []split.Node) (types.Object, int) {
 			return types.Identifier(s), 1
 		}
 	case split.ParenthesisKind:
-		res := types.NewList()
-		if processNodes(l, res) == nil {
+		if len(l) != 0 {
+			if node, consumed := p.handleRuleSlice(l); consumed != 0 {
+				if res, ok := p.placeRuleResult(node, sliced[0].Span(), l, consumed); ok {
+					return res, 1
+				}
+				break
+			}
+		}
+
+		res := types.NewList().SetSpan(sliced[0].Span())
+		if p.processNodes(l, res) == nil {
 			return res, 1
 		}
 	case split.SquareBracketsKind:
@@ -97,31 +243,32 @@ func handleSlice(sliced []split.Node) (types.Object, int) {
 }
 
 // empty string are handled as None, otherwise call handleWord
-func handleSubWord(node split.Node) types.Object {
+func (p *parsing) handleSubWord(node split.Node) types.Object {
 	if _, s, _ := node.Cast(); s == "" {
 		return types.None
 	}
-	o, _ := handleSlice([]split.Node{node})
+	o, _ := p.handleSlice([]split.Node{node})
 	return o
 }
 
 // always return a list with the ListId header
-func handleTypeList(node split.Node) types.Object {
+func (p *parsing) handleTypeList(node split.Node) types.Object {
 	switch k, s, l := node.Cast(); k {
 	case split.StringKind:
 		if s != "" {
-			return types.NewList(names.ListId, handleSubWord(node))
+			return types.NewList(names.ListId, p.handleSubWord(node)).SetSpan(node.Span())
 		}
 	case split.ParenthesisKind, split.SquareBracketsKind, split.CurlyBracesKind:
-		res := types.NewList(names.ListId)
-		if processNodes(l, res) == nil {
+		res := types.NewList(names.ListId).SetSpan(node.Span())
+		if p.processNodes(l, res) == nil {
 			return res
 		}
 	}
 	return types.NewList(names.ListId)
 }
 
-func handleChanType(sliced []split.Node, typeId types.Identifier) (types.Object, int) {
+// handle "typeId[a b]" as (typeId a b)
+func (p *parsing) handleBracketedType(sliced []split.Node, typeId types.Identifier) (types.Object, int) {
 	_, s, _ := sliced[0].Cast()
 	if s != string(typeId) || len(sliced) < 2 {
 		return nil, 0
@@ -133,12 +280,45 @@ func handleChanType(sliced []split.Node, typeId types.Identifier) (types.Object,
 	}
 
 	res := types.NewList(typeId)
-	if processNodes(l, res) == nil {
+	if p.processNodes(l, res) == nil {
 		return res, 2
 	}
 	return nil, 0
 }
 
+// a rule matching the whole content of a parenthesis (like a line) replace it,
+// otherwise the rule result is the head of the list (so it must be appliable)
+func (p *parsing) placeRuleResult(node types.Object, span types.Span, l []split.Node, consumed int) (types.Object, bool) {
+	rest := l[consumed:]
+	if !slices.ContainsFunc(rest, isNotSeparator) {
+		return node, true
+	}
+
+	ruleSpan := spanOf(l[:consumed])
+	switch casted := node.(type) {
+	case types.Identifier:
+	case *types.List:
+		if !casted.Span().IsValid() {
+			casted.SetSpan(ruleSpan)
+		}
+	default:
+		panic(errRulePlace)
+	}
+
+	res := types.NewList().SetSpan(span)
+	res.AddWithSpan(node, ruleSpan)
+	return res, p.processNodes(rest, res) == nil
+}
+
+func isNotSeparator(node split.Node) bool {
+	return !isSeparator(node)
+}
+
+func isSeparator(node split.Node) bool {
+	k, _, _ := node.Cast()
+	return k == split.SeparatorKind
+}
+
 func lenWithoutLastSeparator(l []split.Node) int {
 	res := len(l)
 	if res == 0 {
@@ -152,7 +332,7 @@ func lenWithoutLastSeparator(l []split.Node) int {
 }
 
 // handle "&value" as (& value)
-func parseAddressing(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseAddressing(sliced []split.Node) (types.Object, int) {
 	k, s, _ := sliced[0].Cast()
 	// test len to keep the basic identifier case
 	if k != split.StringKind || s[0] != '&' || len(s) == 1 ||
@@ -161,12 +341,12 @@ func parseAddressing(sliced []split.Node) (types.Object, int) {
 		return nil, 0
 	}
 
-	object, consumed := handleSlice(append([]split.Node{split.StringNode(s[1:])}, sliced[1:]...))
+	object, consumed := p.handleSlice(append([]split.Node{split.SubString(sliced[0], 1, -1)}, sliced[1:]...))
 	return types.NewList(names.AmpersandId, object), consumed
 }
 
 // handle "[n]type" or "[]type" as (slice n type) or (slice type)
-func parseArrayOrSliceType(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseArrayOrSliceType(sliced []split.Node) (types.Object, int) {
 	k, _, l := sliced[0].Cast()
 	if k != split.SquareBracketsKind || len(sliced) < 2 {
 		return nil, 0
@@ -177,7 +357,7 @@ func parseArrayOrSliceType(sliced []split.Node) (types.Object, int) {
 	}
 
 	nodeList := types.NewList(names.SliceId)
-	sizeNode, consumed := handleSlice(l)
+	sizeNode, consumed := p.handleSlice(l)
 	if consumed != lenWithoutLastSeparator(l) {
 		return nil, 0
 	}
@@ -185,66 +365,66 @@ func parseArrayOrSliceType(sliced []split.Node) (types.Object, int) {
 	if consumed != 0 {
 		nodeList.Add(sizeNode)
 	}
-	object, consumed := handleSlice(sliced[1:])
+	object, consumed := p.handleSlice(sliced[1:])
 	nodeList.Add(object)
 	return nodeList, consumed + 1
 }
 
 // handle "<-chan[type]" as (<-chan type)
-func parseArrowChanType(sliced []split.Node) (types.Object, int) {
-	return handleChanType(sliced, names.ArrowChanId)
+func (p *parsing) parseArrowChanType(sliced []split.Node) (types.Object, int) {
+	return p.handleBracketedType(sliced, names.ArrowChanId)
 }
 
 // handle "chan<-[type]" as (chan<- type)
-func parseChanArrowType(sliced []split.Node) (types.Object, int) {
-	return handleChanType(sliced, names.ChanArrowId)
+func (p *parsing) parseChanArrowType(sliced []split.Node) (types.Object, int) {
+	return p.handleBracketedType(sliced, names.ChanArrowId)
 }
 
 // handle "chan[type]" as (chan type)
-func parseChanType(sliced []split.Node) (types.Object, int) {
-	return handleChanType(sliced, names.ChanId)
+func (p *parsing) parseChanType(sliced []split.Node) (types.Object, int) {
+	return p.handleBracketedType(sliced, names.ChanId)
 }
 
 // handle "*a" as (* a)
-func parseDereference(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseDereference(sliced []split.Node) (types.Object, int) {
 	k, s, _ := sliced[0].Cast()
 	// test len to keep the basic identifier case
 	if k != split.StringKind || s[0] != '*' || len(s) == 1 || s == names.MultAssign {
 		return nil, 0
 	}
 
-	object, consumed := handleSlice(append([]split.Node{split.StringNode(s[1:])}, sliced[1:]...))
+	object, consumed := p.handleSlice(append([]split.Node{split.SubString(sliced[0], 1, -1)}, sliced[1:]...))
 	return types.NewList(names.StarId, object), consumed
 }
 
 // handle "a.b.c" as (get a b c)
-func parseDotField(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseDotField(sliced []split.Node) (types.Object, int) {
 	if _, s, _ := sliced[0].Cast(); s == names.Dot {
 		return nil, 0
 	}
-	return splitListSep(sliced, ".", names.GetId)
+	return p.splitListSep(sliced, ".", names.GetId)
 }
 
 // handle "...type" as (... type)
-func parseEllipsis(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseEllipsis(sliced []split.Node) (types.Object, int) {
 	_, s, _ := sliced[0].Cast()
 	// test len to keep the basic identifier case
 	if !strings.HasPrefix(s, string(names.EllipsisId)) || len(s) == 3 {
 		return nil, 0
 	}
 
-	object, consumed := handleSlice(append([]split.Node{split.StringNode(s[3:])}, sliced[1:]...))
+	object, consumed := p.handleSlice(append([]split.Node{split.SubString(sliced[0], 3, -1)}, sliced[1:]...))
 	return types.NewList(names.EllipsisId, object), consumed
 }
 
-func parseFalse(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseFalse(sliced []split.Node) (types.Object, int) {
 	if _, s, _ := sliced[0].Cast(); s == "false" {
 		return types.Boolean(false), 1
 	}
 	return nil, 0
 }
 
-func parseFloat(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseFloat(sliced []split.Node) (types.Object, int) {
 	_, s, _ := sliced[0].Cast()
 	if f, err := strconv.ParseFloat(s, 64); err == nil {
 		return types.Float(f), 1
@@ -254,20 +434,25 @@ func parseFloat(sliced []split.Node) (types.Object, int) {
 
 // handle "func[typeList]typeList2" as (func typeList typeList2),
 // typeList format is "t1 t2" as (list t1 t2)
-// typeList2 format could be "t1" or "(t1 t2)" as (list t1) or (list t1 t2)
-func parseFuncType(sliced []split.Node) (types.Object, int) {
-	if _, s, _ := sliced[0].Cast(); s != string(names.FuncId) || len(sliced) < 3 {
+// typeList2 format could be "t1" or "(t1 t2)" as (list t1) or (list t1 t2) (with "name:type" for named results),
+// "func[typeList]" followed by a space is (func typeList)
+func (p *parsing) parseFuncType(sliced []split.Node) (types.Object, int) {
+	if _, s, _ := sliced[0].Cast(); s != string(names.FuncId) || len(sliced) < 2 {
 		return nil, 0
 	}
 	if k, _, _ := sliced[1].Cast(); k != split.SquareBracketsKind {
 		return nil, 0
 	}
-	return types.NewList(names.FuncId, handleTypeList(sliced[1]), handleTypeList(sliced[2])), 3
+	if len(sliced) == 2 || isSeparator(sliced[2]) {
+		// no result
+		return types.NewList(names.FuncId, p.handleTypeList(sliced[1])), 2
+	}
+	return types.NewList(names.FuncId, p.handleTypeList(sliced[1]), p.handleTypeList(sliced[2])), 3
 }
 
 // handle "type[typeList]" as (gen type typeList)
 // typeList format is "t1 t2" as (list t1 t2) where t1 and t2 can be any node (including "name:type" format)
-func parseGenericType(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseGenericType(sliced []split.Node) (types.Object, int) {
 	if len(sliced) < 2 {
 		return nil, 0
 	}
@@ -275,10 +460,10 @@ func parseGenericType(sliced []split.Node) (types.Object, int) {
 	if k, _, _ := sliced[1].Cast(); k != split.SquareBracketsKind {
 		return nil, 0
 	}
-	return types.NewList(names.GenId, handleSubWord(sliced[0]), handleTypeList(sliced[1])), 2
+	return types.NewList(names.GenId, p.handleSubWord(sliced[0]), p.handleTypeList(sliced[1])), 2
 }
 
-func parseInt(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseInt(sliced []split.Node) (types.Object, int) {
 	_, s, _ := sliced[0].Cast()
 	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
 		return types.Integer(i), 1
@@ -286,29 +471,35 @@ func parseInt(sliced []split.Node) (types.Object, int) {
 	return nil, 0
 }
 
+// handle "interface[Name:func[params]results other]" as (interface (list Name (func params results)) other)
+// where other is an embedded interface or a type constraint
+func (p *parsing) parseInterfaceType(sliced []split.Node) (types.Object, int) {
+	return p.handleBracketedType(sliced, names.InterfaceId)
+}
+
 // handle "a:b:c" as (list a b c)
-func parseList(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseList(sliced []split.Node) (types.Object, int) {
 	// exception for ":="
 	if _, s, _ := sliced[0].Cast(); s == names.DeclareAssign {
 		return nil, 0
 	}
-	return splitListSep(sliced, ":", names.ListId)
+	return p.splitListSep(sliced, ":", names.ListId)
 }
 
 // handle "$type" as (lit type)
 // mark a type in order to use it as literal
-func parseLiteral(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseLiteral(sliced []split.Node) (types.Object, int) {
 	k, s, _ := sliced[0].Cast()
 	if k != split.StringKind || s[0] != '$' {
 		return nil, 0
 	}
 
-	object, consumed := handleSlice(append([]split.Node{split.StringNode(s[1:])}, sliced[1:]...))
+	object, consumed := p.handleSlice(append([]split.Node{split.SubString(sliced[0], 1, -1)}, sliced[1:]...))
 	return types.NewList(names.LitId, object), consumed
 }
 
 // handle "map[t1]t2" as (map t1 t2)
-func parseMapType(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseMapType(sliced []split.Node) (types.Object, int) {
 	if _, s, _ := sliced[0].Cast(); s != string(names.MapId) || len(sliced) < 3 {
 		return nil, 0
 	}
@@ -318,16 +509,16 @@ func parseMapType(sliced []split.Node) (types.Object, int) {
 		return nil, 0
 	}
 
-	t1, consumed := handleSlice(l)
+	t1, consumed := p.handleSlice(l)
 	if consumed != lenWithoutLastSeparator(l) || consumed == 0 {
 		return nil, 0
 	}
 
-	t2, consumed := handleSlice(sliced[2:])
+	t2, consumed := p.handleSlice(sliced[2:])
 	return types.NewList(names.MapId, t1, t2), consumed + 2
 }
 
-func parseNone(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseNone(sliced []split.Node) (types.Object, int) {
 	if _, s, _ := sliced[0].Cast(); s == "None" {
 		return types.None, 1
 	}
@@ -335,7 +526,7 @@ func parseNone(sliced []split.Node) (types.Object, int) {
 }
 
 // handle "!b" as (! b)
-func parseNot(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseNot(sliced []split.Node) (types.Object, int) {
 	k, s, _ := sliced[0].Cast()
 	// test len to keep the basic identifier case
 	if k != split.StringKind || s[0] != '!' || len(s) == 1 || s == names.NotEqual {
@@ -343,11 +534,11 @@ func parseNot(sliced []split.Node) (types.Object, int) {
 
 	}
 
-	object, consumed := handleSlice(append([]split.Node{split.StringNode(s[1:])}, sliced[1:]...))
+	object, consumed := p.handleSlice(append([]split.Node{split.SubString(sliced[0], 1, -1)}, sliced[1:]...))
 	return types.NewList(names.NotId, object), consumed
 }
 
-func parseRune(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseRune(sliced []split.Node) (types.Object, int) {
 	k, s, _ := sliced[0].Cast()
 	lastIndex := len(s) - 1
 	if k != split.StringKind || s[0] != '\'' || s[lastIndex] != '\'' {
@@ -358,7 +549,7 @@ func parseRune(sliced []split.Node) (types.Object, int) {
 	return types.Rune([]rune(extracted)[0]), 1
 }
 
-func parseString(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseString(sliced []split.Node) (types.Object, int) {
 	k, s, _ := sliced[0].Cast()
 	if k != split.StringKind || s[0] != '"' || s[len(s)-1] != '"' {
 		return nil, 0
@@ -368,44 +559,91 @@ func parseString(sliced []split.Node) (types.Object, int) {
 	return types.String(extracted), 1
 }
 
+// handle "struct[name:type other]" as (struct (list name type) other) where other is an embedded type
+func (p *parsing) parseStructType(sliced []split.Node) (types.Object, int) {
+	return p.handleBracketedType(sliced, names.StructId)
+}
+
 // handle "~type" as (~ type)
-func parseTilde(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseTilde(sliced []split.Node) (types.Object, int) {
 	k, s, _ := sliced[0].Cast()
 	// test len to keep the basic identifier case
 	if k != split.StringKind || s[0] != '~' || len(s) == 1 {
 		return nil, 0
 	}
 
-	object, consumed := handleSlice(append([]split.Node{split.StringNode(s[1:])}, sliced[1:]...))
+	object, consumed := p.handleSlice(append([]split.Node{split.SubString(sliced[0], 1, -1)}, sliced[1:]...))
 	return types.NewList(names.TildeId, object), consumed
 }
 
-func parseTrue(sliced []split.Node) (types.Object, int) {
+func (p *parsing) parseTrue(sliced []split.Node) (types.Object, int) {
 	if _, s, _ := sliced[0].Cast(); s == "true" {
 		return types.Boolean(true), 1
 	}
 	return nil, 0
 }
 
-// handle ",a" as (quote a)
-func parseUnquote(sliced []split.Node) (types.Object, int) {
+// handle "`a" as (quasiquote a)
+func (p *parsing) parseQuasiquote(sliced []split.Node) (types.Object, int) {
+	k, s, _ := sliced[0].Cast()
+	if k != split.StringKind || s[0] != '`' {
+		return nil, 0
+	}
+
+	object, consumed := p.handlePrefixed(sliced, 1)
+	if consumed == 0 {
+		return nil, 0
+	}
+	return types.NewList(names.QuasiquoteId, object), consumed
+}
+
+// handle ",a" as (unquote a) and ",@a" as (unquote-splicing a)
+func (p *parsing) parseUnquote(sliced []split.Node) (types.Object, int) {
 	k, s, _ := sliced[0].Cast()
 	if k != split.StringKind || s[0] != ',' {
 		return nil, 0
 	}
 
-	object, consumed := handleSlice(append([]split.Node{split.StringNode(s[1:])}, sliced[1:]...))
-	return types.NewList(names.UnquoteId, object), consumed
+	header, prefixSize := names.UnquoteId, 1
+	if strings.HasPrefix(s, ",@") {
+		header, prefixSize = names.UnquoteSplicingId, 2
+	}
+
+	object, consumed := p.handlePrefixed(sliced, prefixSize)
+	if consumed == 0 {
+		return nil, 0
+	}
+	return types.NewList(header, object), consumed
+}
+
+// parse what follows a prefix of prefixSize bytes,
+// the prefix can be alone in its node when it is directly followed by a list
+func (p *parsing) handlePrefixed(sliced []split.Node, prefixSize int) (types.Object, int) {
+	if _, s, _ := sliced[0].Cast(); len(s) == prefixSize {
+		if len(sliced) == 1 {
+			return nil, 0
+		}
+		if k, _, _ := sliced[1].Cast(); k == split.SeparatorKind {
+			return nil, 0
+		}
+
+		object, consumed := p.handleSlice(sliced[1:])
+		if consumed == 0 {
+			return nil, 0
+		}
+		return object, consumed + 1
+	}
+	return p.handleSlice(append([]split.Node{split.SubString(sliced[0], prefixSize, -1)}, sliced[1:]...))
 }
 
-func skipSeparator(sliced []split.Node) (types.Object, int) {
+func (p *parsing) skipSeparator(sliced []split.Node) (types.Object, int) {
 	if k, _, _ := sliced[0].Cast(); k == split.SeparatorKind {
 		return nil, -1
 	}
 	return nil, 0
 }
 
-func splitListSep(sliced []split.Node, sep string, typeId types.Identifier) (types.Object, int) {
+func (p *parsing) splitListSep(sliced []split.Node, sep string, typeId types.Identifier) (types.Object, int) {
 	for index, node := range sliced {
 		if k, _, _ := node.Cast(); k == split.SeparatorKind {
 			sliced = sliced[:index]
@@ -417,20 +655,30 @@ func splitListSep(sliced []split.Node, sep string, typeId types.Identifier) (typ
 	var nodes []split.Node
 	res := types.NewList(typeId)
 	for _, node := range sliced {
-		if k, s, _ := node.Cast(); k == split.StringKind {
+		// the separator could be in a quoted string
+		if k, s, _ := node.Cast(); k == split.StringKind && !isQuoted(s) {
 			splitted := strings.Split(s, sep)
 			last := len(splitted) - 1
 			if last < 1 {
+				nodes = append(nodes, node)
 				continue
 			}
 
 			notFound = false
-			object, _ := handleSlice(append(nodes, split.StringNode(splitted[0])))
-			res.Add(object)
-			for i := 1; i < last; {
-				res.Add(handleSubWord(split.StringNode(splitted[i])))
+			start, end := 0, len(splitted[0])
+			if first := split.SubString(node, start, end); end != 0 {
+				nodes = append(nodes, first)
+			}
+			p.addSplitPart(res, nodes)
+			for i := 1; i < last; i++ {
+				start, end = end+len(sep), end+len(sep)+len(splitted[i])
+				subNode := split.SubString(node, start, end)
+				res.AddWithSpan(locateList(p.handleSubWord(subNode), subNode.Span()), subNode.Span())
+			}
+			nodes = nodes[:0]
+			if end+len(sep) != len(s) {
+				nodes = append(nodes, split.SubString(node, end+len(sep), -1))
 			}
-			nodes = append(nodes[:0], split.StringNode(splitted[last]))
 		} else {
 			nodes = append(nodes, node)
 		}
@@ -440,7 +688,30 @@ func splitListSep(sliced []split.Node, sep string, typeId types.Identifier) (typ
 		return nil, 0
 	}
 
-	object, _ := handleSlice(nodes)
-	res.Add(object)
+	p.addSplitPart(res, nodes)
 	return res, len(sliced)
 }
+
+// an empty part (like in "a:" or ":b") is None
+func (p *parsing) addSplitPart(res *types.List, nodes []split.Node) {
+	if len(nodes) == 0 {
+		res.Add(types.None)
+		return
+	}
+
+	object, _ := p.handleSlice(nodes)
+	span := spanOf(nodes)
+	res.AddWithSpan(locateList(object, span), span)
+}
+
+// give its span to a list built by a rule
+func locateList(object types.Object, span types.Span) types.Object {
+	if casted, ok := object.(*types.List); ok && !casted.Span().IsValid() {
+		casted.SetSpan(span)
+	}
+	return object
+}
+
+func isQuoted(s string) bool {
+	return s != "" && (s[0] == '"' || s[0] == '\'')
+}
@@ -15,8 +15,11 @@ package split
 
 import (
 	"errors"
+	"fmt"
 	"iter"
 	"unicode"
+
+	"github.com/dvaumoron/foresee/types"
 )
 
 const (
@@ -36,37 +39,96 @@ var (
 type Kind uint8
 
 type Node interface {
+	types.Locatable
 	Cast() (Kind, string, []Node)
 }
 
 type listNode struct {
 	nodes []Node
 	kind  Kind
+	span  types.Span
 }
 
 func (l listNode) Cast() (Kind, string, []Node) {
 	return l.kind, "", l.nodes
 }
 
+func (l listNode) Span() types.Span {
+	return l.span
+}
+
 type separatorNode struct{}
 
 func (s separatorNode) Cast() (Kind, string, []Node) {
 	return SeparatorKind, "", nil
 }
 
-type StringNode string
+func (s separatorNode) Span() types.Span {
+	return types.Span{}
+}
+
+type StringNode struct {
+	value string
+	span  types.Span
+}
 
 func (s StringNode) Cast() (Kind, string, []Node) {
-	return StringKind, string(s), nil
+	return StringKind, s.value, nil
+}
+
+func (s StringNode) Span() types.Span {
+	return s.span
+}
+
+func MakeStringNode(value string, span types.Span) StringNode {
+	return StringNode{value: value, span: span}
+}
+
+// build a node with value[start:end] of the string in node (end < 0 means until the end),
+// the span is adjusted when the node is on a single line
+func SubString(node Node, start int, end int) StringNode {
+	_, s, _ := node.Cast()
+	if end < 0 {
+		end = len(s)
+	}
+
+	span := node.Span()
+	if span.IsValid() && span.Start.Line == span.End.Line {
+		span.End.Col = span.Start.Col + end - 1
+		span.Start.Col += start
+	}
+	return StringNode{value: s[start:end], span: span}
+}
+
+// Keep the characters of a word and the span of them.
+type wordBuffer struct {
+	chars []rune
+	span  types.Span
 }
 
-func yieldBuffer(yield func(Node) bool, buffer []rune) ([]rune, bool) {
-	if len(buffer) == 0 {
-		return buffer, true
+func (b *wordBuffer) append(char rune, pos types.Position) {
+	if len(b.chars) == 0 {
+		b.span.Start = pos
 	}
+	b.chars = append(b.chars, char)
+	b.span.End = pos
+}
 
-	ok := yield(StringNode(buffer))
-	return buffer[:0], ok
+// yield the buffered word (if any) and reset the buffer
+func (b *wordBuffer) yield(yield func(Node) bool) bool {
+	if len(b.chars) == 0 {
+		return true
+	}
+
+	node := StringNode{value: string(b.chars), span: b.span}
+	b.chars = b.chars[:0]
+	return yield(node)
+}
+
+type charHandler = func(rune, types.Position) bool
+
+func locatedError(fileName string, pos types.Position, err error) error {
+	return fmt.Errorf("%v : %w", types.Span{File: fileName, Start: pos, End: pos}, err)
 }
 
 func yieldSeparator(yield func(Node) bool) bool {
@@ -77,48 +139,49 @@ func yieldNothing(yield func(Node) bool) bool {
 	return true
 }
 
-func consumeString(yieldChar *func(rune) bool, delim rune, yield func(Node) bool, depthPtr *int) {
+func consumeString(handlerPtr *charHandler, delim rune, start types.Position, fileName string, yield func(Node) bool, depthPtr *int) {
 	*depthPtr++
-	previousYieldChar := *yieldChar
+	previousHandler := *handlerPtr
 
-	var directAppender func(char rune) bool
+	var directAppender charHandler
 
 	buffer := []rune{delim}
-	stoppableAppender := func(char rune) bool {
+	stoppableAppender := func(char rune, pos types.Position) bool {
 		switch char {
 		case delim:
 			*depthPtr--
-			*yieldChar = previousYieldChar
-			return yield(StringNode(append(buffer, delim)))
+			*handlerPtr = previousHandler
+			span := types.Span{File: fileName, Start: start, End: pos}
+			return yield(StringNode{value: string(append(buffer, delim)), span: span})
 		case '\\':
 			buffer = append(buffer, char)
-			*yieldChar = directAppender
+			*handlerPtr = directAppender
 		default:
 			buffer = append(buffer, char)
 		}
 		return true
 	}
 
-	directAppender = func(char rune) bool {
+	directAppender = func(char rune, _ types.Position) bool {
 		buffer = append(buffer, char)
-		*yieldChar = stoppableAppender
+		*handlerPtr = stoppableAppender
 		return true
 	}
 
-	*yieldChar = stoppableAppender
+	*handlerPtr = stoppableAppender
 }
 
-func SmartSplit(chars iter.Seq[rune], registerError func(error)) iter.Seq[Node] {
-	var buffer []rune
-	yielder, ok := yieldSeparator, true
+// positions in chars are used to compute the span of nodes
+func SmartSplit(fileName string, chars iter.Seq2[rune, types.Position], registerError func(error)) iter.Seq[Node] {
 	return func(yield func(Node) bool) {
 		depth := 0
-		var yieldChar func(char rune) bool
-		topYieldChar := func(char rune) bool {
+		buffer := wordBuffer{span: types.Span{File: fileName}}
+		yielder := yieldSeparator
+		var handler charHandler
+		topHandler := func(char rune, pos types.Position) bool {
 			switch {
 			case unicode.IsSpace(char):
-				buffer, ok = yieldBuffer(yield, buffer)
-				if !ok {
+				if !buffer.yield(yield) {
 					return false
 				}
 
@@ -127,66 +190,64 @@ func SmartSplit(chars iter.Seq[rune], registerError func(error)) iter.Seq[Node]
 				}
 				yielder = yieldNothing
 			case char == '"', char == '\'':
-				buffer, ok = yieldBuffer(yield, buffer)
-				if !ok {
+				if !buffer.yield(yield) {
 					return false
 				}
 
-				consumeString(&yieldChar, char, yield, &depth)
+				consumeString(&handler, char, pos, fileName, yield, &depth)
 				yielder = yieldSeparator
 			case char == '(':
-				buffer, ok = yieldBuffer(yield, buffer)
-				if !ok {
+				if !buffer.yield(yield) {
 					return false
 				}
 
-				splitSub(&yieldChar, ')', ParenthesisKind, yield, registerError, &depth)
+				splitSub(&handler, ')', ParenthesisKind, pos, fileName, yield, registerError, &depth)
 				yielder = yieldSeparator
 			case char == '[':
-				buffer, ok = yieldBuffer(yield, buffer)
-				if !ok {
+				if !buffer.yield(yield) {
 					return false
 				}
 
-				splitSub(&yieldChar, ']', SquareBracketsKind, yield, registerError, &depth)
+				splitSub(&handler, ']', SquareBracketsKind, pos, fileName, yield, registerError, &depth)
 				yielder = yieldSeparator
 			case char == '{':
-				buffer, ok = yieldBuffer(yield, buffer)
-				if !ok {
+				if !buffer.yield(yield) {
 					return false
 				}
 
-				splitSub(&yieldChar, '}', CurlyBracesKind, yield, registerError, &depth)
+				splitSub(&handler, '}', CurlyBracesKind, pos, fileName, yield, registerError, &depth)
 				yielder = yieldSeparator
 			case char == ')', char == ']', char == '}':
-				registerError(errParsingUnexpectedClosing)
+				registerError(locatedError(fileName, pos, errParsingUnexpectedClosing))
 				return false
 			default:
-				buffer = append(buffer, char)
+				buffer.append(char, pos)
 				yielder = yieldSeparator
 			}
 			return true
 		}
 
-		yieldChar = topYieldChar
-		for char := range chars {
-			if !yieldChar(char) {
+		var lastPos types.Position
+		handler = topHandler
+		for char, pos := range chars {
+			if !handler(char, pos) {
 				return
 			}
+			lastPos = pos
 		}
 
 		if depth != 0 {
-			registerError(errParsingClosing)
+			registerError(locatedError(fileName, lastPos, errParsingClosing))
 			return
 		}
 
-		yieldBuffer(yield, buffer)
+		buffer.yield(yield)
 	}
 }
 
-func splitSub(yieldCharPtr *func(rune) bool, delim rune, kind Kind, yield func(Node) bool, registerError func(error), depthPtr *int) {
+func splitSub(handlerPtr *charHandler, delim rune, kind Kind, start types.Position, fileName string, yield func(Node) bool, registerError func(error), depthPtr *int) {
 	*depthPtr++
-	previousYieldChar := *yieldCharPtr
+	previousHandler := *handlerPtr
 
 	var splitted []Node
 	localYield := func(node Node) bool {
@@ -194,40 +255,42 @@ func splitSub(yieldCharPtr *func(rune) bool, delim rune, kind Kind, yield func(N
 		return true
 	}
 
-	var buffer []rune
+	buffer := wordBuffer{span: types.Span{File: fileName}}
 	yielder := yieldSeparator
-	*yieldCharPtr = func(char rune) bool {
+	*handlerPtr = func(char rune, pos types.Position) bool {
 		switch {
 		case char == delim:
-			yieldBuffer(localYield, buffer)
+			buffer.yield(localYield)
 			*depthPtr--
-			*yieldCharPtr = previousYieldChar
-			return yield(listNode{nodes: splitted, kind: kind})
+			*handlerPtr = previousHandler
+			span := types.Span{File: fileName, Start: start, End: pos}
+			return yield(listNode{nodes: splitted, kind: kind, span: span})
 		case unicode.IsSpace(char):
-			buffer, _ = yieldBuffer(localYield, buffer)
+			buffer.yield(localYield)
 			yielder(localYield)
 			yielder = yieldNothing
 		case char == '"', char == '\'':
-			buffer, _ = yieldBuffer(localYield, buffer)
-			consumeString(yieldCharPtr, char, localYield, depthPtr)
+			buffer.yield(localYield)
+			consumeString(handlerPtr, char, pos, fileName, localYield, depthPtr)
 			yielder = yieldSeparator
 		case char == '(':
-			buffer, _ = yieldBuffer(localYield, buffer)
-			splitSub(yieldCharPtr, ')', ParenthesisKind, localYield, registerError, depthPtr)
+			buffer.yield(localYield)
+			splitSub(handlerPtr, ')', ParenthesisKind, pos, fileName, localYield, registerError, depthPtr)
 			yielder = yieldSeparator
 		case char == '[':
-			buffer, _ = yieldBuffer(localYield, buffer)
-			splitSub(yieldCharPtr, ']', SquareBracketsKind, localYield, registerError, depthPtr)
+			buffer.yield(localYield)
+			splitSub(handlerPtr, ']', SquareBracketsKind, pos, fileName, localYield, registerError, depthPtr)
 			yielder = yieldSeparator
 		case char == '{':
-			buffer, _ = yieldBuffer(localYield, buffer)
-			splitSub(yieldCharPtr, '}', CurlyBracesKind, localYield, registerError, depthPtr)
+			buffer.yield(localYield)
+			splitSub(handlerPtr, '}', CurlyBracesKind, pos, fileName, localYield, registerError, depthPtr)
 			yielder = yieldSeparator
 		case char == ')', char == ']', char == '}':
-			registerError(errParsingWrongClosing)
+			registerError(locatedError(fileName, pos, errParsingWrongClosing))
 			return false
 		default:
-			buffer = append(buffer, char)
+			buffer.append(char, pos)
+			yielder = yieldSeparator
 		}
 		return true
 	}
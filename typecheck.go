@@ -0,0 +1,224 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"go/ast"
+	"go/importer"
+	goparser "go/parser"
+	"go/scanner"
+	"go/token"
+	gotypes "go/types"
+	"strings"
+	"sync"
+
+	"github.com/dvaumoron/foresee/builtins/names"
+	"github.com/dvaumoron/foresee/diagnostic"
+	"github.com/dvaumoron/foresee/infer"
+	"github.com/dvaumoron/foresee/types"
+)
+
+// share the imported packages between the checks of a run,
+// the checked foresee packages are used by their dependents
+type checkImporter struct {
+	mutex    sync.Mutex
+	fallback gotypes.Importer
+	checked  map[string]*gotypes.Package
+}
+
+func newCheckImporter() *checkImporter {
+	fallback := importer.ForCompiler(token.NewFileSet(), "gc", infer.LookupExport)
+	return &checkImporter{fallback: fallback, checked: map[string]*gotypes.Package{}}
+}
+
+func (i *checkImporter) Import(path string) (*gotypes.Package, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if pkg, ok := i.checked[path]; ok {
+		return pkg, nil
+	}
+	return i.fallback.Import(path)
+}
+
+func (i *checkImporter) store(path string, pkg *gotypes.Package) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.checked[path] = pkg
+}
+
+// locate the errors of the generated Go in the foresee sources
+type positionMapper struct {
+	fset        *token.FileSet
+	outputPaths []string
+	goFiles     []*ast.File
+	decls       []map[string]types.Span // span of the top level forms by declared name, by file
+	filePaths   []string
+}
+
+// parse and type check the rendered files of pkg (in the same order as its files)
+func typeCheck(pkg *packageFiles, infereds []*types.List, rendered [][]byte, opts *options, imports *checkImporter) diagnostic.List {
+	mapper := &positionMapper{
+		fset: token.NewFileSet(), outputPaths: make([]string, len(rendered)), goFiles: make([]*ast.File, len(rendered)),
+		decls: make([]map[string]types.Span, len(rendered)), filePaths: pkg.filePaths,
+	}
+
+	var diagnostics diagnostic.List
+	for index, data := range rendered {
+		mapper.outputPaths[index] = opts.outputPath(pkg.filePaths[index])
+		mapper.decls[index] = declaredSpans(infereds[index])
+
+		goFile, err := goparser.ParseFile(mapper.fset, mapper.outputPaths[index], data, goparser.SkipObjectResolution)
+		var errList scanner.ErrorList
+		if errors.As(err, &errList) {
+			for _, goErr := range errList {
+				diagnostics = append(diagnostics, mapper.diagnostic(goErr.Pos, goErr.Msg))
+			}
+		}
+		mapper.goFiles[index] = goFile
+	}
+	if len(diagnostics) != 0 {
+		return diagnostics
+	}
+
+	config := gotypes.Config{Importer: imports, Error: func(err error) {
+		if goErr, ok := err.(gotypes.Error); ok {
+			diagnostics = append(diagnostics, mapper.diagnostic(goErr.Fset.Position(goErr.Pos), goErr.Msg))
+		}
+	}}
+	checked, _ := config.Check(pkg.path, mapper.fset, mapper.goFiles, nil)
+	if checked != nil {
+		imports.store(pkg.path, checked)
+	}
+	return diagnostics
+}
+
+// the error is located on the foresee form declaring the Go declaration which contains it
+func (m *positionMapper) diagnostic(position token.Position, message string) diagnostic.Diagnostic {
+	d := diagnostic.Diagnostic{Severity: diagnostic.Error, Message: "generated Go : " + message}
+	if strings.HasSuffix(position.Filename, fileExt) { // already located by a line directive
+		d.Span.File = position.Filename
+		d.Span.Start = types.Position{Line: position.Line, Col: position.Column}
+		d.Span.End = d.Span.Start
+		return d
+	}
+
+	d.Message += " (at " + position.String() + ")"
+	for index, outputPath := range m.outputPaths {
+		if outputPath != position.Filename {
+			continue
+		}
+
+		d.Span.File = m.filePaths[index]
+		if goFile := m.goFiles[index]; goFile != nil {
+			pos := m.fset.File(goFile.Pos()).Pos(position.Offset)
+			for _, decl := range goFile.Decls {
+				if decl.Pos() <= pos && pos <= decl.End() {
+					for _, name := range goDeclNames(decl) {
+						if span, ok := m.decls[index][name]; ok {
+							d.Span = span
+							return d
+						}
+					}
+				}
+			}
+		}
+		return d
+	}
+	return d
+}
+
+func goDeclNames(decl ast.Decl) []string {
+	var res []string
+	switch casted := decl.(type) {
+	case *ast.FuncDecl:
+		res = append(res, casted.Name.Name)
+	case *ast.GenDecl:
+		for _, spec := range casted.Specs {
+			switch castedSpec := spec.(type) {
+			case *ast.TypeSpec:
+				res = append(res, castedSpec.Name.Name)
+			case *ast.ValueSpec:
+				for _, name := range castedSpec.Names {
+					res = append(res, name.Name)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// span of the top level forms of l by declared name (the first form wins)
+func declaredSpans(l *types.List) map[string]types.Span {
+	res := map[string]types.Span{}
+	for index := range l.Size() {
+		form, ok := l.LoadInt(index).(*types.List)
+		if !ok {
+			continue
+		}
+
+		span := l.SpanInt(index)
+		if !span.IsValid() {
+			span = form.Span()
+		}
+		for _, name := range formDeclNames(form) {
+			if _, ok := res[name]; !ok {
+				res[name] = span
+			}
+		}
+	}
+	return res
+}
+
+// handle "(func name ...)", "(func (receiver) name ...)", "(type name ...)",
+// "(var name ...)" and "(const name ...)", the names could be generic ("(gen name ...)")
+func formDeclNames(form *types.List) []string {
+	header, _ := form.LoadInt(0).(types.Identifier)
+	switch header {
+	case names.FuncId:
+		nameDesc := form.LoadInt(1)
+		if receiver, ok := nameDesc.(*types.List); ok {
+			if receiverHeader, _ := receiver.LoadInt(0).(types.Identifier); receiverHeader != names.GenId {
+				nameDesc = form.LoadInt(2)
+			}
+		}
+		return declName(nameDesc)
+	case names.Type, names.Var, names.Const:
+		return declName(form.LoadInt(1))
+	}
+	return nil
+}
+
+func declName(o types.Object) []string {
+	switch casted := o.(type) {
+	case types.Identifier:
+		return []string{string(casted)}
+	case *types.List:
+		switch header, _ := casted.LoadInt(0).(types.Identifier); header {
+		case names.GenId, names.ListId:
+			return declName(casted.LoadInt(1))
+		}
+
+		var res []string
+		for elem := range casted.Iter() {
+			if id, ok := elem.(types.Identifier); ok {
+				res = append(res, string(id))
+			}
+		}
+		return res
+	}
+	return nil
+}
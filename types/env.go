@@ -53,6 +53,15 @@ func (b BaseEnvironment) StoreStr(key string, value Object) {
 	b.objects[key] = value
 }
 
+// Return false (without storing) when key is unknown.
+func (b BaseEnvironment) UpdateStr(key string, value Object) bool {
+	if _, ok := b.objects[key]; !ok {
+		return false
+	}
+	b.objects[key] = value
+	return true
+}
+
 func (b BaseEnvironment) Delete(key Object) {
 	if id, ok := key.(Identifier); ok {
 		b.DeleteStr(string(id))
@@ -106,6 +115,18 @@ func (l LocalEnvironment) Load(key Object) Object {
 	return Load(l, key)
 }
 
+// Store value in the environment defining key (searching the local parents),
+// the base environment at the root (shared like builtins) is never changed.
+func (l LocalEnvironment) UpdateStr(key string, value Object) bool {
+	if l.BaseEnvironment.UpdateStr(key, value) {
+		return true
+	}
+	if parent, ok := l.parent.(LocalEnvironment); ok {
+		return parent.UpdateStr(key, value)
+	}
+	return false
+}
+
 func MakeLocalEnvironment(env Environment) LocalEnvironment {
 	return LocalEnvironment{BaseEnvironment: MakeBaseEnvironment(), parent: env}
 }
@@ -41,6 +41,11 @@ type Environment interface {
 	CopyTo(Environment)
 }
 
+// Environment able to change a value where it is defined (not only locally).
+type Updatable interface {
+	UpdateStr(string, Object) bool
+}
+
 type Renderer interface {
 	Render(io.Writer) error
 }
@@ -54,6 +59,10 @@ type Sizable interface {
 	Size() int
 }
 
+type Locatable interface {
+	Span() Span
+}
+
 type Iterable interface {
 	Object
 	Iter() iter.Seq[Object]
@@ -19,12 +19,28 @@ import (
 	"slices"
 )
 
+// spans is nil or has the same size as inner,
+// it keeps the position of elements which can not carry one (like Identifier)
 type List struct {
 	inner []Object
+	spans []Span
+	span  Span
 }
 
 func (l *List) Add(value Object) *List {
 	l.inner = append(l.inner, value)
+	if l.spans != nil {
+		l.spans = append(l.spans, Span{})
+	}
+	return l
+}
+
+func (l *List) AddWithSpan(value Object, span Span) *List {
+	if l.spans == nil {
+		l.spans = make([]Span, len(l.inner), cap(l.inner))
+	}
+	l.inner = append(l.inner, value)
+	l.spans = append(l.spans, span)
 	return l
 }
 
@@ -78,7 +94,11 @@ func (l *List) Load(key Object) Object {
 		if 0 > start || start > end || end > max {
 			return &List{}
 		}
-		return &List{inner: l.inner[start:end]}
+		res := &List{inner: l.inner[start:end], span: l.span}
+		if l.spans != nil {
+			res.spans = l.spans[start:end]
+		}
+		return res
 	}
 	return None
 }
@@ -93,6 +113,38 @@ func (l *List) Store(key Object, value Object) {
 	}
 }
 
+// No panic with nil receiver
+func (l *List) Span() Span {
+	if l == nil {
+		return Span{}
+	}
+	return l.span
+}
+
+func (l *List) SetSpan(span Span) *List {
+	l.span = span
+	return l
+}
+
+// Return the most precise known position of the element at index
+// (its own, the one recorded at insertion, or the one of the list).
+// No panic with nil receiver
+func (l *List) SpanInt(index int) Span {
+	if l == nil || index < 0 || index >= len(l.inner) {
+		return l.Span()
+	}
+
+	if located, ok := l.inner[index].(Locatable); ok {
+		if span := located.Span(); span.IsValid() {
+			return span
+		}
+	}
+	if l.spans != nil && l.spans[index].IsValid() {
+		return l.spans[index]
+	}
+	return l.span
+}
+
 func (l *List) Size() int {
 	if l == nil {
 		return 0
@@ -0,0 +1,74 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package types
+
+import "strconv"
+
+// Line and column start at 1, column count bytes (like go/token).
+type Position struct {
+	Line int
+	Col  int
+}
+
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Col)
+}
+
+// End is inclusive (position of the last character).
+type Span struct {
+	File  string
+	Start Position
+	End   Position
+}
+
+func (s Span) IsValid() bool {
+	return s.Start.IsValid()
+}
+
+// Return a span covering s and other (other is returned when s is not valid).
+func (s Span) Merge(other Span) Span {
+	if !s.IsValid() {
+		return other
+	}
+	if !other.IsValid() {
+		return s
+	}
+
+	if other.Start.Line < s.Start.Line || (other.Start.Line == s.Start.Line && other.Start.Col < s.Start.Col) {
+		s.Start = other.Start
+	}
+	if other.End.Line > s.End.Line || (other.End.Line == s.End.Line && other.End.Col > s.End.Col) {
+		s.End = other.End
+	}
+	return s
+}
+
+// Format as "file:line:col" (file part omitted when unknown).
+func (s Span) String() string {
+	if !s.IsValid() {
+		if s.File == "" {
+			return "-"
+		}
+		return s.File
+	}
+
+	if s.File == "" {
+		return s.Start.String()
+	}
+	return s.File + ":" + s.Start.String()
+}
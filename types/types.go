@@ -137,8 +137,8 @@ type NativeAppliable struct {
 	inner NativeFunc
 }
 
-func (n NativeAppliable) Apply(env Environment, it Iterable) Object {
-	return n.inner(env, it.Iter())
+func (n NativeAppliable) Apply(env Environment, it iter.Seq[Object]) Object {
+	return n.inner(env, it)
 }
 
 func MakeNativeAppliable(f NativeFunc) NativeAppliable {
@@ -0,0 +1,66 @@
+/*
+ *
+ * Copyright 2023 foresee authors.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * This Source Code Form is "Incompatible With Secondary Licenses", as
+ * defined by the Mozilla Public License, v. 2.0.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"time"
+)
+
+// enough to detect an edition without reading the file
+type fileStamp struct {
+	modTime int64
+	size    int64
+}
+
+// poll the listed foresee files or the ones of the current directory (no dependency on file system notifications),
+// a change regenerate the edited packages and their dependents (the cache skip the others)
+func watch(opts *options, interval time.Duration, listed []string) {
+	if len(listed) == 0 {
+		fmt.Fprintln(os.Stderr, "Watching current directory, checking every", interval)
+	} else {
+		fmt.Fprintln(os.Stderr, "Watching listed files, checking every", interval)
+	}
+
+	var previous map[string]fileStamp
+	for {
+		filePaths := opts.sources(listed) // new files of a listed directory are seen
+		if current := stampFiles(filePaths); !maps.Equal(previous, current) {
+			if previous != nil {
+				fmt.Fprintln(os.Stderr, "Change detected at", time.Now().Format(time.TimeOnly))
+			}
+			previous = current
+
+			if failed := processPackages(filePaths, opts); failed == 0 {
+				fmt.Fprintln(os.Stderr, "All files generated")
+			} else {
+				fmt.Fprintln(os.Stderr, failed, "file(s) failed")
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// unreadable files are ignored
+func stampFiles(filePaths []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(filePaths))
+	for _, filePath := range filePaths {
+		if info, err := os.Stat(filePath); err == nil {
+			stamps[filePath] = fileStamp{modTime: info.ModTime().UnixNano(), size: info.Size()}
+		}
+	}
+	return stamps
+}